@@ -41,11 +41,15 @@ func (s *AccountService) Get(domain string, username string) (*Account, error) {
 }
 
 // Create creates a new account in the specified domain with the given username
-// and password.
+// and password. The password must satisfy the configured password policy and
+// must not be in the breached password list, if one is configured.
 func (s *AccountService) Create(domain, username, password string) error {
 	if err := ValidateEmailFromParts(username, domain); err != nil {
 		return err
 	}
+	if err := s.CheckPassword(domain, username, password); err != nil {
+		return err
+	}
 
 	return s.client.Accounts.Create(domain, username, password)
 }
@@ -105,11 +109,16 @@ func (s *AccountService) Rename(domain, old, new string) error {
 	return s.client.Accounts.Update(domain, old, ur)
 }
 
-// ChangePassword changes the password for the specified account.
+// ChangePassword changes the password for the specified account. The password
+// must satisfy the configured password policy and must not be in the breached
+// password list, if one is configured.
 func (s *AccountService) ChangePassword(domain, username, password string) error {
 	if err := ValidateEmailFromParts(username, domain); err != nil {
 		return err
 	}
+	if err := s.CheckPassword(domain, username, password); err != nil {
+		return err
+	}
 
 	account, err := s.client.Accounts.Get(domain, username)
 	if err != nil {
@@ -123,3 +132,17 @@ func (s *AccountService) ChangePassword(domain, username, password string) error
 	}
 	return s.client.Accounts.Update(domain, username, ur)
 }
+
+// PasswordPolicy returns the password policy which new passwords must satisfy.
+func (s *AccountService) PasswordPolicy() *PasswordPolicy {
+	return s.passwordPolicy
+}
+
+// CheckPassword checks the password against the password policy and the breached
+// password list, as is done when an account is created or its password is changed.
+func (s *AccountService) CheckPassword(domain, username, password string) error {
+	if err := s.passwordPolicy.Validate(password, username, domain); err != nil {
+		return err
+	}
+	return s.breachedPasswords.Check(password)
+}
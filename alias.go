@@ -65,11 +65,36 @@ func (s *AliasService) GetForEmail(domain, alias, email string) (*Alias, error)
 	return &Alias{Alias: a}, nil
 }
 
-// Create assignes email to the specified alias.
+// Create assignes email to the specified alias. The change is refused with an
+// *AliasChainError if it would create a forwarding loop or exceed the maximum alias
+// chain depth.
 func (s *AliasService) Create(domain, alias, email string) error {
+	if err := validateAlias(domain, alias, email); err != nil {
+		return err
+	}
+	if err := s.checkChain(domain, alias, email); err != nil {
+		return err
+	}
+	return s.client.Aliases.Create(domain, alias, email)
+}
+
+// create assigns email to the specified alias without checking the alias graph. It is
+// used by composite operations which restore or copy existing aliases.
+func (s *AliasService) create(domain, alias, email string) error {
+	if err := validateAlias(domain, alias, email); err != nil {
+		return err
+	}
 	return s.client.Aliases.Create(domain, alias, email)
 }
 
+// validateAlias validates the alias and recipient addresses.
+func validateAlias(domain, alias, email string) error {
+	if err := ValidateEmailFromParts(alias, domain); err != nil {
+		return err
+	}
+	return ValidateEmail(email)
+}
+
 // Delete deletes the specified alias.
 func (s *AliasService) Delete(domain, alias, email string) error {
 	if err := ValidateEmailFromParts(alias, domain); err != nil {
@@ -78,7 +103,8 @@ func (s *AliasService) Delete(domain, alias, email string) error {
 	return s.client.Aliases.Delete(domain, alias, email)
 }
 
-// DeleteAll deletes all recipients for a specific alias.
+// DeleteAll deletes all recipients for a specific alias. If deleting one of the
+// recipients fails, the already deleted recipients are restored.
 func (s *AliasService) DeleteAll(domain, alias string) error {
 	if err := ValidateEmailFromParts(alias, domain); err != nil {
 		return err
@@ -88,15 +114,40 @@ func (s *AliasService) DeleteAll(domain, alias string) error {
 	if err != nil {
 		return err
 	}
-	for _, a := range aliases {
+	tx := NewTransaction()
+	err = s.executor.Run("Deleting aliases", aliasEmails(aliases), func(i int) error {
+		a := aliases[i]
 		if err := s.client.Aliases.Delete(domain, alias, a.Email); err != nil {
 			return err
 		}
+		tx.Completed(fmt.Sprintf("delete alias %s@%s -> %s", alias, domain, a.Email), func() error {
+			return s.restore(domain, &a)
+		})
+		return nil
+	})
+	if err != nil {
+		return tx.Rollback(fmt.Sprintf("delete alias %s@%s", alias, domain), err)
 	}
 
 	return nil
 }
 
+// restore recreates a deleted alias with its previous state.
+func (s *AliasService) restore(domain string, a *goprsc.Alias) error {
+	if err := s.create(domain, a.Name, a.Email); err != nil {
+		return err
+	}
+	if a.Enabled {
+		return nil
+	}
+	ur := &goprsc.AliasUpdateRequest{
+		Name:    a.Name,
+		Email:   a.Email,
+		Enabled: false,
+	}
+	return s.client.Aliases.Update(domain, a.Name, a.Email, ur)
+}
+
 // Enable enables the specified alias.
 func (s *AliasService) Enable(domain, alias, email string) error {
 	return s.setEnabled(domain, alias, email, true)
@@ -120,6 +171,12 @@ func (s *AliasService) setEnabled(domain, alias, email string, enabled bool) err
 	if err != nil {
 		return err
 	}
+	if enabled && !a.Enabled {
+		// Disabled aliases don't forward mail, so they are not part of the alias graph.
+		if err := s.checkChain(domain, alias, email); err != nil {
+			return err
+		}
+	}
 
 	ur := &goprsc.AliasUpdateRequest{
 		Name:    alias,
@@ -130,7 +187,12 @@ func (s *AliasService) setEnabled(domain, alias, email string, enabled bool) err
 }
 
 // Rename changes the username part of the specified alias forwarding to the specified email address.
+// If the alias is enabled, the change is refused with an *AliasChainError if it would create a
+// forwarding loop or exceed the maximum alias chain depth.
 func (s *AliasService) Rename(domain, alias, email, newName string) error {
+	if err := validateAlias(domain, alias, email); err != nil {
+		return err
+	}
 	if err := ValidateEmailFromParts(newName, domain); err != nil {
 		return err
 	}
@@ -139,6 +201,11 @@ func (s *AliasService) Rename(domain, alias, email, newName string) error {
 	if err != nil {
 		return err
 	}
+	if a.Enabled {
+		if err := s.checkChain(domain, newName, email, [2]string{fmt.Sprintf("%s@%s", alias, domain), email}); err != nil {
+			return err
+		}
+	}
 	ur := &goprsc.AliasUpdateRequest{
 		Name:    newName,
 		Email:   email,
@@ -147,8 +214,46 @@ func (s *AliasService) Rename(domain, alias, email, newName string) error {
 	return s.client.Aliases.Update(domain, alias, email, ur)
 }
 
+// ChangeRecipient changes the recipient email address of the specified alias from 'email' to 'newEmail'.
+// The change is refused with an *AliasChainError if it would create a forwarding loop or exceed
+// the maximum alias chain depth.
+func (s *AliasService) ChangeRecipient(domain, alias, email, newEmail string) error {
+	return s.changeRecipient(domain, alias, email, newEmail, s.newAliasChecker())
+}
+
+// changeRecipient changes the recipient of the alias, checking the change with the checker
+// if the alias is enabled. A nil checker skips the check.
+func (s *AliasService) changeRecipient(domain, alias, email, newEmail string, checker *aliasChecker) error {
+	if err := ValidateEmailFromParts(alias, domain); err != nil {
+		return err
+	}
+	if err := ValidateEmail(newEmail); err != nil {
+		return err
+	}
+
+	a, err := s.client.Aliases.GetForEmail(domain, alias, email)
+	if err != nil {
+		return err
+	}
+	if checker != nil && a.Enabled {
+		if err := checker.check(domain, alias, newEmail, [2]string{fmt.Sprintf("%s@%s", alias, domain), email}); err != nil {
+			return err
+		}
+	}
+	ur := &goprsc.AliasUpdateRequest{
+		Name:    alias,
+		Email:   newEmail,
+		Enabled: a.Enabled,
+	}
+	return s.client.Aliases.Update(domain, alias, email, ur)
+}
+
 // RenameAll renames the username part of the specified aliases (for all recipients attached to the alias).
+// If renaming one of the recipients fails, the already renamed recipients are renamed back.
 func (s *AliasService) RenameAll(domain, alias, newName string) error {
+	if err := ValidateEmailFromParts(alias, domain); err != nil {
+		return err
+	}
 	if err := ValidateEmailFromParts(newName, domain); err != nil {
 		return err
 	}
@@ -157,7 +262,12 @@ func (s *AliasService) RenameAll(domain, alias, newName string) error {
 	if err != nil {
 		return err
 	}
-	for _, a := range aliases {
+	if err := s.checkRenameAll(domain, alias, newName, aliases); err != nil {
+		return err
+	}
+	tx := NewTransaction()
+	err = s.executor.Run("Renaming aliases", aliasEmails(aliases), func(i int) error {
+		a := aliases[i]
 		ur := &goprsc.AliasUpdateRequest{
 			Name:    newName,
 			Email:   a.Email,
@@ -166,7 +276,52 @@ func (s *AliasService) RenameAll(domain, alias, newName string) error {
 		if err := s.client.Aliases.Update(domain, alias, a.Email, ur); err != nil {
 			return err
 		}
+		tx.Completed(fmt.Sprintf("rename alias %s@%s -> %s to %s", alias, domain, a.Email, newName), func() error {
+			ur := &goprsc.AliasUpdateRequest{
+				Name:    alias,
+				Email:   a.Email,
+				Enabled: a.Enabled,
+			}
+			return s.client.Aliases.Update(domain, newName, a.Email, ur)
+		})
+		return nil
+	})
+	if err != nil {
+		return tx.Rollback(fmt.Sprintf("rename alias %s@%s to %s", alias, domain, newName), err)
 	}
 
 	return nil
 }
+
+// checkRenameAll checks that renaming all recipients of the alias creates no forwarding
+// loop and doesn't exceed the maximum alias chain depth. Disabled recipients forward no
+// mail, so they are not checked.
+func (s *AliasService) checkRenameAll(domain, alias, newName string, aliases []goprsc.Alias) error {
+	graph, err := s.loadAliasGraph()
+	if err != nil {
+		return err
+	}
+	address := fmt.Sprintf("%s@%s", alias, domain)
+	newAddress := fmt.Sprintf("%s@%s", newName, domain)
+	for _, a := range aliases {
+		graph.remove(address, a.Email)
+	}
+	for _, a := range aliases {
+		if !a.Enabled {
+			continue
+		}
+		if err := graph.check(newAddress, a.Email, s.maxAliasChainDepth); err != nil {
+			return err
+		}
+		graph.add(newAddress, a.Email)
+	}
+	return nil
+}
+
+func aliasEmails(aliases []goprsc.Alias) []string {
+	emails := make([]string, len(aliases))
+	for i, a := range aliases {
+		emails[i] = a.Email
+	}
+	return emails
+}
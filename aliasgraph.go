@@ -0,0 +1,234 @@
+package emailctl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const defaultMaxAliasChainDepth = 10
+
+// LoadMaxAliasChainDepth returns the 'alias.maxChainDepth' setting, which is the maximum
+// number of aliases mail may pass through before it reaches a mailbox or external address.
+// Zero means no limit.
+func LoadMaxAliasChainDepth() int {
+	if viper.IsSet("alias.maxChainDepth") {
+		return viper.GetInt("alias.maxChainDepth")
+	}
+	return defaultMaxAliasChainDepth
+}
+
+// AliasChainError is returned when an alias change would create a forwarding loop or
+// a chain of aliases longer than the configured maximum.
+type AliasChainError struct {
+	// Alias is the address of the changed alias.
+	Alias string
+	// Email is the recipient address of the changed alias.
+	Email string
+	// Loop reports whether the change would create a forwarding loop.
+	Loop bool
+	// Depth is the length of the longest alias chain through the alias after the change.
+	Depth int
+	// MaxDepth is the configured maximum chain depth.
+	MaxDepth int
+}
+
+func (e *AliasChainError) Error() string {
+	if e.Loop {
+		return fmt.Sprintf("forwarding %s to %s would create a forwarding loop", e.Alias, e.Email)
+	}
+	return fmt.Sprintf("forwarding %s to %s would create a chain of %d aliases, the maximum is %d (alias.maxChainDepth)",
+		e.Alias, e.Email, e.Depth, e.MaxDepth)
+}
+
+// aliasGraph maps the lower-case addresses of the enabled aliases of all domains to the
+// lower-case addresses of their recipients.
+type aliasGraph map[string][]string
+
+// loadAliasGraph lists the aliases of all domains concurrently and builds the alias graph.
+func (s *AliasService) loadAliasGraph() (aliasGraph, error) {
+	domains, err := s.client.Domains.List()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(domains))
+	for i, d := range domains {
+		names[i] = d.Name
+	}
+	aliases := make([][]Alias, len(domains))
+	// The check is part of another command, so no progress bar is drawn.
+	executor := &Executor{Concurrency: s.executor.Concurrency, RateLimit: s.executor.RateLimit}
+	err = executor.Run("Loading aliases", names, func(i int) error {
+		var err error
+		aliases[i], err = s.List(names[i])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	graph := make(aliasGraph)
+	for i, d := range domains {
+		for _, a := range aliases[i] {
+			if a.Enabled {
+				graph.add(fmt.Sprintf("%s@%s", a.Name, d.Name), a.Email)
+			}
+		}
+	}
+	return graph, nil
+}
+
+func (g aliasGraph) add(from, to string) {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	if from != to {
+		g[from] = append(g[from], to)
+	}
+}
+
+// remove removes the edge from 'from' to 'to', if there is one.
+func (g aliasGraph) remove(from, to string) {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	edges := g[from]
+	for i, e := range edges {
+		if e == to {
+			g[from] = append(edges[:i:i], edges[i+1:]...)
+			return
+		}
+	}
+}
+
+// reaches reports whether mail to 'from' is forwarded to 'to' through the aliases.
+func (g aliasGraph) reaches(from, to string) bool {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	visited := make(map[string]bool)
+	var visit func(n string) bool
+	visit = func(n string) bool {
+		if n == to {
+			return true
+		}
+		if visited[n] {
+			return false
+		}
+		visited[n] = true
+		for _, next := range g[n] {
+			if visit(next) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(from)
+}
+
+// depthFrom returns the number of aliases on the longest chain starting at email.
+func (g aliasGraph) depthFrom(email string) int {
+	return longestPath(g, strings.ToLower(email), make(map[string]bool), make(map[string]int))
+}
+
+// depthTo returns the number of aliases on the longest chain ending at email, not
+// counting email itself.
+func (g aliasGraph) depthTo(email string) int {
+	reverse := make(aliasGraph)
+	for from, edges := range g {
+		for _, to := range edges {
+			reverse[to] = append(reverse[to], from)
+		}
+	}
+	return longestPath(reverse, strings.ToLower(email), make(map[string]bool), make(map[string]int))
+}
+
+// longestPath returns the number of nodes with edges on the longest path starting at n.
+// The result for each node is kept in 'lengths', so nodes reached through several paths
+// are only visited once. Nodes on the current path are skipped, so existing loops don't
+// recurse forever.
+func longestPath(g aliasGraph, n string, path map[string]bool, lengths map[string]int) int {
+	if len(g[n]) == 0 || path[n] {
+		return 0
+	}
+	if l, ok := lengths[n]; ok {
+		return l
+	}
+	path[n] = true
+	longest := 0
+	for _, next := range g[n] {
+		if l := longestPath(g, next, path, lengths); l > longest {
+			longest = l
+		}
+	}
+	delete(path, n)
+	lengths[n] = longest + 1
+	return longest + 1
+}
+
+// checkChain returns an *AliasChainError if forwarding alias@domain to email would create
+// a forwarding loop or exceed the maximum alias chain depth. The edges in 'replaced' are
+// removed from the graph first, as they are replaced by the change.
+func (s *AliasService) checkChain(domain, alias, email string, replaced ...[2]string) error {
+	return s.newAliasChecker().check(domain, alias, email, replaced...)
+}
+
+// aliasChecker checks the alias changes of a composite operation, like moving an account,
+// against an alias graph which is loaded once and updated with each change. It is not
+// safe for concurrent use.
+type aliasChecker struct {
+	service *AliasService
+	graph   aliasGraph
+}
+
+func (s *AliasService) newAliasChecker() *aliasChecker {
+	return &aliasChecker{service: s}
+}
+
+// check returns an *AliasChainError if forwarding alias@domain to email would create a
+// forwarding loop or exceed the maximum alias chain depth, once the edges in 'replaced'
+// are removed. If the change is allowed, it is applied to the graph.
+func (c *aliasChecker) check(domain, alias, email string, replaced ...[2]string) error {
+	if c.graph == nil {
+		graph, err := c.service.loadAliasGraph()
+		if err != nil {
+			return err
+		}
+		c.graph = graph
+	}
+	for _, r := range replaced {
+		c.graph.remove(r[0], r[1])
+	}
+	address := fmt.Sprintf("%s@%s", alias, domain)
+	if err := c.graph.check(address, email, c.service.maxAliasChainDepth); err != nil {
+		for _, r := range replaced {
+			c.graph.add(r[0], r[1])
+		}
+		return err
+	}
+	c.graph.add(address, email)
+	return nil
+}
+
+// remove removes a deleted or disabled alias from the graph.
+func (c *aliasChecker) remove(domain, alias, email string) {
+	if c.graph != nil {
+		c.graph.remove(fmt.Sprintf("%s@%s", alias, domain), email)
+	}
+}
+
+// check returns an *AliasChainError if adding an edge from the alias address to email
+// would create a loop or a chain of more than maxDepth aliases.
+func (g aliasGraph) check(address, email string, maxDepth int) error {
+	if strings.EqualFold(address, email) {
+		// An alias to itself keeps a copy in the mailbox.
+		return nil
+	}
+	if g.reaches(email, address) {
+		return &AliasChainError{Alias: address, Email: email, Loop: true}
+	}
+	if maxDepth <= 0 {
+		return nil
+	}
+	depth := g.depthTo(address) + 1 + g.depthFrom(email)
+	if depth > maxDepth {
+		return &AliasChainError{Alias: address, Email: email, Depth: depth, MaxDepth: maxDepth}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package emailctl
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newAliasGraph builds an alias graph from pairs of alias and recipient addresses.
+func newAliasGraph(edges ...[2]string) aliasGraph {
+	g := make(aliasGraph)
+	for _, e := range edges {
+		g.add(e[0], e[1])
+	}
+	return g
+}
+
+func TestAliasGraphDepth(t *testing.T) {
+	g := newAliasGraph(
+		[2]string{"a@x", "b@x"},
+		[2]string{"b@x", "c@x"},
+		[2]string{"c@x", "john@x"},
+		[2]string{"d@x", "b@x"},
+		[2]string{"e@x", "b@x"},
+		[2]string{"e@x", "john@x"},
+		[2]string{"loop1@x", "loop2@x"},
+		[2]string{"loop2@x", "loop1@x"},
+		[2]string{"Self@X", "self@x"},
+	)
+	tests := []struct {
+		email     string
+		depthFrom int
+		depthTo   int
+	}{
+		{"a@x", 3, 0},
+		{"b@x", 2, 1},
+		{"c@x", 1, 2},
+		{"John@X", 0, 3},
+		{"e@x", 3, 0},
+		{"unknown@x", 0, 0},
+		{"loop1@x", 2, 2},
+		{"self@x", 0, 0},
+	}
+	for _, tt := range tests {
+		if depth := g.depthFrom(tt.email); depth != tt.depthFrom {
+			t.Errorf("depthFrom(%s) = %d, want %d", tt.email, depth, tt.depthFrom)
+		}
+		if depth := g.depthTo(tt.email); depth != tt.depthTo {
+			t.Errorf("depthTo(%s) = %d, want %d", tt.email, depth, tt.depthTo)
+		}
+	}
+}
+
+// TestAliasGraphDepthDiamonds checks that a chain of diamonds, which has 2^40 paths, is
+// measured without walking each of them.
+func TestAliasGraphDepthDiamonds(t *testing.T) {
+	const diamonds = 40
+	g := make(aliasGraph)
+	for i := 0; i < diamonds; i++ {
+		top, bottom := fmt.Sprintf("n%d@x", i), fmt.Sprintf("n%d@x", i+1)
+		for _, side := range []string{"l", "r"} {
+			middle := fmt.Sprintf("%s%d@x", side, i)
+			g.add(top, middle)
+			g.add(middle, bottom)
+		}
+	}
+	if depth := g.depthFrom("n0@x"); depth != 2*diamonds {
+		t.Errorf("depthFrom(n0@x) = %d, want %d", depth, 2*diamonds)
+	}
+	if depth := g.depthTo(fmt.Sprintf("n%d@x", diamonds)); depth != 2*diamonds {
+		t.Errorf("depthTo(n%d@x) = %d, want %d", diamonds, depth, 2*diamonds)
+	}
+}
+
+func TestAliasGraphCheck(t *testing.T) {
+	g := newAliasGraph(
+		[2]string{"a@x", "b@x"},
+		[2]string{"b@x", "c@x"},
+		[2]string{"c@x", "john@x"},
+	)
+	tests := []struct {
+		address  string
+		email    string
+		maxDepth int
+		loop     bool
+		depth    int
+	}{
+		{"d@x", "a@x", 10, false, 0},
+		{"d@x", "a@x", 4, false, 0},
+		{"d@x", "a@x", 3, false, 4},
+		{"d@x", "a@x", 0, false, 0},
+		{"c@x", "a@x", 10, true, 0},
+		{"C@X", "A@x", 0, true, 0},
+		{"john@x", "a@x", 10, true, 0},
+		{"john@x", "john@x", 1, false, 0},
+		{"a@x", "ext@y", 10, false, 0},
+		{"john@x", "ext@y", 3, false, 4},
+	}
+	for _, tt := range tests {
+		err := g.check(tt.address, tt.email, tt.maxDepth)
+		if !tt.loop && tt.depth == 0 {
+			if err != nil {
+				t.Errorf("check(%s, %s, %d) = %v, want nil", tt.address, tt.email, tt.maxDepth, err)
+			}
+			continue
+		}
+		chainErr, ok := err.(*AliasChainError)
+		if !ok {
+			t.Errorf("check(%s, %s, %d) = %v, want an *AliasChainError", tt.address, tt.email, tt.maxDepth, err)
+			continue
+		}
+		if chainErr.Loop != tt.loop || chainErr.Depth != tt.depth {
+			t.Errorf("check(%s, %s, %d) = loop %t, depth %d, want loop %t, depth %d",
+				tt.address, tt.email, tt.maxDepth, chainErr.Loop, chainErr.Depth, tt.loop, tt.depth)
+		}
+	}
+}
+
+func TestAliasGraphRemove(t *testing.T) {
+	g := newAliasGraph(
+		[2]string{"a@x", "b@x"},
+		[2]string{"b@x", "a@x"},
+	)
+	if !g.reaches("b@x", "a@x") {
+		t.Fatal("b@x doesn't reach a@x")
+	}
+	if err := g.check("c@x", "b@x", 0); err != nil {
+		t.Fatalf("check(c@x, b@x) = %v, want nil", err)
+	}
+	g.remove("B@X", "A@X")
+	if g.reaches("b@x", "a@x") {
+		t.Error("b@x still reaches a@x after the edge was removed")
+	}
+	if !g.reaches("a@x", "b@x") {
+		t.Error("a@x doesn't reach b@x after the reverse edge was removed")
+	}
+}
@@ -0,0 +1,473 @@
+package emailctl
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lyubenblagoev/goprsc"
+	"github.com/spf13/viper"
+)
+
+// AuditRecord is an entry in the audit journal describing a single mutating API request.
+type AuditRecord struct {
+	// ID uniquely identifies the record.
+	ID string `json:"id"`
+	// Session identifies the emailctl invocation which made the request.
+	Session string `json:"session"`
+	// Time is the time the request was made.
+	Time time.Time `json:"time"`
+	// User is the operating system user running emailctl.
+	User string `json:"user"`
+	// Login is the Postfix REST Server login used for the request.
+	Login string `json:"login,omitempty"`
+	// Server is the address of the Postfix REST Server.
+	Server string `json:"server"`
+	// Command is the emailctl command line.
+	Command string `json:"command,omitempty"`
+	// Operation is a short description of the request, e.g. "update account".
+	Operation string `json:"operation"`
+	// Resource is the API path of the affected resource, e.g. "domains/example.com/accounts/user".
+	Resource string `json:"resource"`
+	// Target is the affected domain or email address.
+	Target string `json:"target"`
+	// Arguments is the request body with secrets redacted.
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	// Before is the state of the resource before an update or delete request.
+	Before json.RawMessage `json:"before,omitempty"`
+	// Undoes is the ID of the record reverted by an undo record.
+	Undoes string `json:"undoes,omitempty"`
+	// Status is the HTTP status code of the response.
+	Status int `json:"status,omitempty"`
+	// Error describes why the request failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// Succeeded reports whether the request recorded by r succeeded.
+func (r *AuditRecord) Succeeded() bool {
+	return r.Error == "" && (r.Status == 0 || r.Status >= 200 && r.Status <= 299)
+}
+
+// AuditFilter selects records from the audit journal. Empty fields match any record.
+type AuditFilter struct {
+	// Resource matches records whose resource path or target contains the value.
+	Resource string
+	// Operator matches records whose operating system user or login equals the value.
+	Operator string
+	// Operation matches records whose operation contains the value.
+	Operation string
+	// Since matches records made at or after the given time.
+	Since time.Time
+	// Until matches records made before the given time.
+	Until time.Time
+}
+
+// Matches reports whether the record satisfies the filter.
+func (f *AuditFilter) Matches(r *AuditRecord) bool {
+	if f.Resource != "" {
+		resource := strings.ToLower(f.Resource)
+		if !strings.Contains(strings.ToLower(r.Resource), resource) && !strings.Contains(strings.ToLower(r.Target), resource) {
+			return false
+		}
+	}
+	if f.Operator != "" && !strings.EqualFold(f.Operator, r.User) && !strings.EqualFold(f.Operator, r.Login) {
+		return false
+	}
+	if f.Operation != "" && !strings.Contains(r.Operation, strings.ToLower(f.Operation)) {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !r.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// AuditJournal is a local, append-only journal of mutating API requests stored as
+// one JSON record per line.
+type AuditJournal struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewAuditJournal creates an AuditJournal stored in the file at path.
+func NewAuditJournal(path string) *AuditJournal {
+	return &AuditJournal{path: path}
+}
+
+// LoadAuditJournal returns the audit journal configured with the 'audit.file' setting,
+// defaulting to $HOME/.emailctl-audit.jsonl, or nil if 'audit.enabled' is false.
+func LoadAuditJournal() (*AuditJournal, error) {
+	if viper.IsSet("audit.enabled") && !viper.GetBool("audit.enabled") {
+		return nil, nil
+	}
+	path := viper.GetString("audit.file")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".emailctl-audit.jsonl")
+	}
+	return NewAuditJournal(path), nil
+}
+
+// Path returns the path of the journal file.
+func (j *AuditJournal) Path() string {
+	return j.path
+}
+
+// Append appends the record to the journal.
+func (j *AuditJournal) Append(r *AuditRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read returns the records matching the filter in the order they were appended.
+// A missing journal file is treated as an empty journal.
+func (j *AuditJournal) Read(filter *AuditFilter) ([]AuditRecord, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid audit record: %v", j.path, line, err)
+		}
+		if filter == nil || filter.Matches(&r) {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// auditTransport records every mutating request in the audit journal, together with
+// the state of the affected resource before the request.
+type auditTransport struct {
+	next    http.RoundTripper
+	journal *AuditJournal
+	session string
+	user    string
+	command string
+	client  *goprsc.Client
+
+	mu sync.Mutex
+	// fetched holds the most recent response bodies of GET requests for single resources
+	// by URL. The services read most resources right before updating them, so these serve
+	// as the before-images of the updates.
+	fetched map[string][]byte
+}
+
+func newAuditTransport(next http.RoundTripper, journal *AuditJournal) *auditTransport {
+	return &auditTransport{
+		next:    next,
+		journal: journal,
+		session: randomID(4),
+		user:    currentUser(),
+		command: strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " "),
+		fetched: make(map[string][]byte),
+	}
+}
+
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isMutating(req) {
+		return t.get(req)
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &AuditRecord{
+		ID:        randomID(6),
+		Session:   t.session,
+		Time:      time.Now(),
+		User:      t.user,
+		Server:    req.URL.Host,
+		Command:   t.command,
+		Arguments: redactBody(body),
+	}
+	if t.client != nil {
+		r.Login = t.client.Login
+	}
+	res := parseResource(req.Method, req.URL.Path, body)
+	r.Operation = res.operation(req.Method)
+	r.Resource = res.path()
+	r.Target = res.target()
+	if req.Method != http.MethodPost {
+		if before := t.before(req); json.Valid(before) {
+			r.Before = before
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		// The authTransport refreshes the tokens and sends the same request again, which is recorded
+		// instead. The before-image is also read again, with the new token. The body is
+		// restored, as the retry sends it again.
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		r.Status = resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			r.Error = http.StatusText(resp.StatusCode)
+		}
+	}
+	t.mu.Lock()
+	delete(t.fetched, req.URL.String())
+	t.mu.Unlock()
+
+	if jerr := t.journal.Append(r); jerr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: unable to write to the audit journal: %v\n", jerr)
+	}
+	return resp, err
+}
+
+// get sends a read request and remembers the response if it describes a single resource.
+func (t *auditTransport) get(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	if !parseResource(req.Method, req.URL.Path, nil).complete() {
+		return resp, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	t.mu.Lock()
+	t.fetched[req.URL.String()] = body
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// before returns the state of the resource targeted by the mutating request, reusing
+// the response of an earlier read of the same resource if there was one.
+func (t *auditTransport) before(req *http.Request) json.RawMessage {
+	t.mu.Lock()
+	body, ok := t.fetched[req.URL.String()]
+	t.mu.Unlock()
+	if ok {
+		return body
+	}
+
+	get, err := http.NewRequest(http.MethodGet, req.URL.String(), nil)
+	if err != nil {
+		return nil
+	}
+	for _, h := range []string{"Accept", "Authorization", "User-Agent"} {
+		get.Header.Set(h, req.Header.Get(h))
+	}
+	resp, err := t.next.RoundTrip(get)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Resource kinds as recognized in API paths.
+const (
+	domainResource       = "domain"
+	accountResource      = "account"
+	aliasResource        = "alias"
+	senderBccResource    = "sender-bcc"
+	recipientBccResource = "recipient-bcc"
+)
+
+// resource identifies the object addressed by an API request.
+type resource struct {
+	kind    string
+	domain  string
+	account string
+	alias   string
+	email   string
+}
+
+// parseResource determines the resource addressed by the API request. For create
+// requests the name of the new object is taken from the request body.
+func parseResource(method, urlPath string, body []byte) resource {
+	p := urlPath
+	if i := strings.Index(p, "/api/v1/"); i >= 0 {
+		p = p[i+len("/api/v1/"):]
+	}
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+
+	var fields struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	if method == http.MethodPost {
+		json.Unmarshal(body, &fields)
+	}
+
+	var r resource
+	if len(segments) < 1 || segments[0] != "domains" {
+		return r
+	}
+	r.kind = domainResource
+	if len(segments) == 1 {
+		r.domain = fields.Name
+		return r
+	}
+	r.domain = segments[1]
+	if len(segments) < 3 {
+		return r
+	}
+
+	switch segments[2] {
+	case "accounts":
+		r.kind = accountResource
+		r.account = fields.Username
+		if len(segments) > 3 {
+			r.account = segments[3]
+		}
+		if len(segments) > 5 && segments[4] == "bccs" {
+			r.kind = recipientBccResource
+			if segments[5] == "outgoing" {
+				r.kind = senderBccResource
+			}
+			r.email = fields.Email
+		}
+	case "aliases":
+		r.kind = aliasResource
+		r.alias, r.email = fields.Name, fields.Email
+		if len(segments) > 3 {
+			r.alias = segments[3]
+		}
+		if len(segments) > 4 {
+			r.email = strings.Join(segments[4:], "/")
+		}
+	}
+	return r
+}
+
+// complete reports whether r identifies a single resource rather than a collection.
+func (r resource) complete() bool {
+	switch r.kind {
+	case domainResource:
+		return r.domain != ""
+	case accountResource, senderBccResource, recipientBccResource:
+		return r.domain != "" && r.account != ""
+	case aliasResource:
+		return r.domain != "" && r.alias != "" && r.email != ""
+	}
+	return false
+}
+
+func (r resource) operation(method string) string {
+	verb := "update"
+	switch method {
+	case http.MethodPost:
+		verb = "create"
+	case http.MethodDelete:
+		verb = "delete"
+	}
+	if r.kind == "" {
+		return verb
+	}
+	return verb + " " + r.kind
+}
+
+// path returns the canonical API path of the resource.
+func (r resource) path() string {
+	switch r.kind {
+	case domainResource:
+		return fmt.Sprintf("domains/%s", r.domain)
+	case accountResource:
+		return fmt.Sprintf("domains/%s/accounts/%s", r.domain, r.account)
+	case senderBccResource:
+		return fmt.Sprintf("domains/%s/accounts/%s/bccs/outgoing", r.domain, r.account)
+	case recipientBccResource:
+		return fmt.Sprintf("domains/%s/accounts/%s/bccs/incoming", r.domain, r.account)
+	case aliasResource:
+		return fmt.Sprintf("domains/%s/aliases/%s/%s", r.domain, r.alias, r.email)
+	}
+	return ""
+}
+
+// target returns the domain name or email address of the resource.
+func (r resource) target() string {
+	switch r.kind {
+	case domainResource:
+		return r.domain
+	case accountResource, senderBccResource, recipientBccResource:
+		return fmt.Sprintf("%s@%s", r.account, r.domain)
+	case aliasResource:
+		return fmt.Sprintf("%s@%s -> %s", r.alias, r.domain, r.email)
+	}
+	return ""
+}
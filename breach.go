@@ -0,0 +1,152 @@
+package emailctl
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// BreachedPasswordError is returned when a password is found in the breached password list.
+type BreachedPasswordError struct {
+	// Count is the number of times the password was seen in breaches, if known.
+	Count int
+}
+
+func (e *BreachedPasswordError) Error() string {
+	if e.Count > 0 {
+		return fmt.Sprintf("password has appeared in %d data breaches and must not be used", e.Count)
+	}
+	return "password has appeared in a data breach and must not be used"
+}
+
+// BreachedPasswordList checks passwords against a local, sorted list of SHA-1 hashes of
+// breached passwords in the Have I Been Pwned format, where each line holds an upper case
+// hexadecimal hash optionally followed by a colon and the number of occurrences.
+// The list is searched with a binary search directly on the file, so it does not need to
+// fit in memory and no network access is required.
+type BreachedPasswordList struct {
+	path string
+}
+
+// NewBreachedPasswordList creates a BreachedPasswordList backed by the file at path.
+func NewBreachedPasswordList(path string) *BreachedPasswordList {
+	return &BreachedPasswordList{path: path}
+}
+
+// LoadBreachedPasswordList returns the breached password list configured with the
+// 'breachedPasswords.file' setting, or nil if the check is not configured.
+func LoadBreachedPasswordList() *BreachedPasswordList {
+	path := viper.GetString("breachedPasswords.file")
+	if path == "" {
+		return nil
+	}
+	return NewBreachedPasswordList(path)
+}
+
+// Check returns a BreachedPasswordError if the password is in the list. A nil list
+// accepts any password.
+func (l *BreachedPasswordList) Check(password string) error {
+	if l == nil {
+		return nil
+	}
+
+	found, count, err := l.Lookup(password)
+	if err != nil {
+		return fmt.Errorf("unable to check the breached password list: %v", err)
+	}
+	if found {
+		return &BreachedPasswordError{Count: count}
+	}
+	return nil
+}
+
+// Lookup searches the list for the password and returns whether it was found and the
+// number of occurrences recorded for it.
+func (l *BreachedPasswordList) Lookup(password string) (bool, int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return false, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, 0, err
+	}
+
+	// Invariant: if the hash is in the file, its line starts in [lo, hi).
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := readLineFrom(f, mid)
+		if err != nil {
+			return false, 0, err
+		}
+		if start >= hi || len(line) == 0 {
+			hi = mid
+			continue
+		}
+
+		entry, count := parseBreachLine(line)
+		switch {
+		case entry == hash:
+			return true, count, nil
+		case entry < hash:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = mid
+		}
+	}
+	return false, 0, nil
+}
+
+// readLineFrom returns the offset and content of the first line starting at or after offset.
+// The returned line includes any trailing carriage return but not the newline.
+func readLineFrom(f *os.File, offset int64) (int64, string, error) {
+	start := offset
+	if offset > 0 {
+		start = offset - 1
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return 0, "", err
+	}
+	r := bufio.NewReader(f)
+	if offset > 0 {
+		// Skip the rest of the line containing offset-1, unless offset is already a line start.
+		skipped, err := r.ReadString('\n')
+		if err == io.EOF {
+			return start + int64(len(skipped)), "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		start += int64(len(skipped))
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	return start, strings.TrimSuffix(line, "\n"), nil
+}
+
+func parseBreachLine(line string) (string, int) {
+	line = strings.TrimSpace(line)
+	parts := strings.SplitN(line, ":", 2)
+	hash := strings.ToUpper(parts[0])
+	if len(parts) < 2 {
+		return hash, 0
+	}
+	count, _ := strconv.Atoi(parts[1])
+	return hash, count
+}
@@ -0,0 +1,126 @@
+package emailctl
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeBreachList writes the SHA-1 hashes of the passwords, sorted, in the Have I Been
+// Pwned format. The count of the i-th password is i+1.
+func writeBreachList(t *testing.T, dir string, passwords []string, counts bool, newline string, trailing bool) string {
+	var lines []string
+	for i, p := range passwords {
+		sum := sha1.Sum([]byte(p))
+		line := strings.ToUpper(hex.EncodeToString(sum[:]))
+		if counts {
+			line += fmt.Sprintf(":%d", i+1)
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	content := strings.Join(lines, newline)
+	if trailing && len(lines) > 0 {
+		content += newline
+	}
+	path := filepath.Join(dir, fmt.Sprintf("list-%d-%t-%q-%t.txt", len(passwords), counts, newline, trailing))
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBreachedPasswordListLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emailctl-breach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		size     int
+		counts   bool
+		newline  string
+		trailing bool
+	}{
+		{0, true, "\n", true},
+		{1, true, "\n", true},
+		{1, false, "\n", false},
+		{2, true, "\n", true},
+		{3, true, "\r\n", true},
+		{10, false, "\n", false},
+		{257, true, "\n", true},
+		{1000, true, "\r\n", false},
+	}
+	for _, tt := range tests {
+		passwords := make([]string, tt.size)
+		for i := range passwords {
+			passwords[i] = fmt.Sprintf("password%d", i)
+		}
+		path := writeBreachList(t, dir, passwords, tt.counts, tt.newline, tt.trailing)
+		list := NewBreachedPasswordList(path)
+
+		for i, p := range passwords {
+			found, count, err := list.Lookup(p)
+			if err != nil {
+				t.Fatalf("%s: Lookup(%q): %v", path, p, err)
+			}
+			want := 0
+			if tt.counts {
+				want = i + 1
+			}
+			if !found || count != want {
+				t.Errorf("%s: Lookup(%q) = %t, %d, want true, %d", path, p, found, count, want)
+			}
+		}
+		for _, p := range []string{"", "not in the list", "password-1", fmt.Sprintf("password%d", tt.size)} {
+			found, _, err := list.Lookup(p)
+			if err != nil {
+				t.Fatalf("%s: Lookup(%q): %v", path, p, err)
+			}
+			if found {
+				t.Errorf("%s: Lookup(%q) found a password which is not in the list", path, p)
+			}
+		}
+	}
+}
+
+func TestBreachedPasswordListCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emailctl-breach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeBreachList(t, dir, []string{"a", "b", "password"}, true, "\n", true)
+
+	tests := []struct {
+		list     *BreachedPasswordList
+		password string
+		count    int
+		breached bool
+		err      bool
+	}{
+		{nil, "password", 0, false, false},
+		{NewBreachedPasswordList(path), "password", 3, true, false},
+		{NewBreachedPasswordList(path), "correct horse", 0, false, false},
+		{NewBreachedPasswordList(filepath.Join(dir, "missing.txt")), "password", 0, false, true},
+	}
+	for _, tt := range tests {
+		err := tt.list.Check(tt.password)
+		breach, breached := err.(*BreachedPasswordError)
+		switch {
+		case breached != tt.breached:
+			t.Errorf("Check(%q) = %v, breached %t, want %t", tt.password, err, breached, tt.breached)
+		case breached && breach.Count != tt.count:
+			t.Errorf("Check(%q) count = %d, want %d", tt.password, breach.Count, tt.count)
+		case !breached && (err != nil) != tt.err:
+			t.Errorf("Check(%q) = %v, want error %t", tt.password, err, tt.err)
+		}
+	}
+}
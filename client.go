@@ -2,6 +2,8 @@ package emailctl
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 
 	"github.com/lyubenblagoev/goprsc"
 	"github.com/spf13/viper"
@@ -10,6 +12,9 @@ import (
 // Client is the Postfix REST server client.
 type Client struct {
 	client *goprsc.Client
+	auth   *authTransport
+	audit  *auditTransport
+	dryRun bool
 
 	Auth       *AuthService
 	Domains    *DomainService
@@ -17,6 +22,9 @@ type Client struct {
 	Aliases    *AliasService
 	InputBccs  *InputBccService
 	OutputBccs *OutputBccService
+
+	// Bulk runs the items of bulk operations.
+	Bulk *Executor
 }
 
 // GetLogin returns the user login associated with the client
@@ -26,27 +34,97 @@ func (c *Client) GetLogin() string {
 
 // GetAuthToken returns the authentication token associated with the client
 func (c *Client) GetAuthToken() string {
-	return c.client.AuthToken
+	authToken, _ := c.auth.tokens()
+	return authToken
 }
 
 // GetRefreshToken returns the refresh token associated with the client
 func (c *Client) GetRefreshToken() string {
-	return c.client.RefreshToken
+	_, refreshToken := c.auth.tokens()
+	return refreshToken
 }
 
 type service struct {
-	client *goprsc.Client
+	client            *goprsc.Client
+	passwordPolicy    *PasswordPolicy
+	breachedPasswords *BreachedPasswordList
+	executor          *Executor
+	// maxAliasChainDepth is the maximum alias chain depth, or zero if there is no limit.
+	maxAliasChainDepth int
+}
+
+// ClientOption is an option to NewClient.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	dryRunOutput io.Writer
+	journal      *AuditJournal
+	executor     *Executor
+}
+
+// DryRunOption returns a ClientOption that makes the client print every mutating
+// API request to w instead of sending it to the server. Read requests are still sent.
+func DryRunOption(w io.Writer) ClientOption {
+	return func(o *clientOptions) {
+		o.dryRunOutput = w
+	}
+}
+
+// AuditOption returns a ClientOption that makes the client record every mutating
+// API request in the audit journal.
+func AuditOption(journal *AuditJournal) ClientOption {
+	return func(o *clientOptions) {
+		o.journal = journal
+	}
+}
+
+// ExecutorOption returns a ClientOption that sets the Executor used for bulk operations.
+// By default, the executor is configured with LoadExecutor.
+func ExecutorOption(executor *Executor) ClientOption {
+	return func(o *clientOptions) {
+		o.executor = executor
+	}
 }
 
 // NewClient creates an instance of Client.
-func NewClient() (*Client, error) {
-	goprscClient, err := newGoprscClient()
+func NewClient(options ...ClientOption) (*Client, error) {
+	opts := &clientOptions{executor: LoadExecutor()}
+	for _, o := range options {
+		o(opts)
+	}
+
+	var audit *auditTransport
+	transport := http.DefaultTransport
+	if opts.journal != nil {
+		audit = newAuditTransport(transport, opts.journal)
+		transport = audit
+	}
+	if opts.dryRunOutput != nil {
+		transport = &dryRunTransport{next: transport, out: opts.dryRunOutput}
+	}
+	auth := &authTransport{
+		next:         transport,
+		authToken:    viper.GetString("authToken"),
+		refreshToken: viper.GetString("refreshToken"),
+	}
+
+	goprscClient, err := newGoprscClient(&http.Client{Transport: auth})
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize Postfix REST Server API client: %s", err)
 	}
+	auth.client = goprscClient
+	if audit != nil {
+		audit.client = goprscClient
+	}
 
-	c := &Client{client: goprscClient}
-	s := service{client: goprscClient} // Reuse the same structure instead of allocating one for each service
+	c := &Client{client: goprscClient, auth: auth, audit: audit, dryRun: opts.dryRunOutput != nil, Bulk: opts.executor}
+	s := service{ // Reuse the same structure instead of allocating one for each service
+		client:             goprscClient,
+		passwordPolicy:     LoadPasswordPolicy(),
+		breachedPasswords:  LoadBreachedPasswordList(),
+		executor:           opts.executor,
+		maxAliasChainDepth: LoadMaxAliasChainDepth(),
+	}
 	c.Auth = (*AuthService)(&s)
 	c.Domains = (*DomainService)(&s)
 	c.Accounts = (*AccountService)(&s)
@@ -57,14 +135,12 @@ func NewClient() (*Client, error) {
 	return c, nil
 }
 
-func newGoprscClient() (*goprsc.Client, error) {
+func newGoprscClient(httpClient *http.Client) (*goprsc.Client, error) {
 	host := viper.GetString("host")
 	port := viper.GetString("port")
 	useHTTPS := viper.GetBool("https")
 
 	login := viper.GetString("login")
-	authToken := viper.GetString("authToken")
-	refreshToken := viper.GetString("refreshToken")
 
 	var options []goprsc.ClientOption
 	options = append(options, goprsc.UserAgentOption("emailctl"))
@@ -73,9 +149,11 @@ func newGoprscClient() (*goprsc.Client, error) {
 	if useHTTPS {
 		options = append(options, goprsc.HTTPSProtocolOption())
 	}
-	if len(authToken) > 0 {
-		options = append(options, goprsc.AuthOption(login, authToken, refreshToken))
+	// The tokens are kept by the authTransport, which also refreshes them, as goprsc
+	// doesn't guard them against concurrent requests.
+	if len(viper.GetString("authToken")) > 0 {
+		options = append(options, goprsc.AuthOption(login, "", ""))
 	}
 
-	return goprsc.NewClientWithOptions(nil, options...)
+	return goprsc.NewClientWithOptions(httpClient, options...)
 }
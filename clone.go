@@ -0,0 +1,211 @@
+package emailctl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DomainClone is a plan for copying the accounts, aliases and BCCs of a domain into
+// a new domain.
+type DomainClone struct {
+	// Source is the copied domain.
+	Source *DomainState
+	// Target is the name of the new domain.
+	Target string
+	// Accounts are the copied accounts with their BCCs.
+	Accounts []*AccountState
+	// Aliases are the copied aliases.
+	Aliases []Alias
+}
+
+// PlanClone plans copying the accounts and aliases of the domain whose name include
+// returns true for into the target domain. The state must include the BCCs.
+func (d *DomainState) PlanClone(target string, include func(name string) bool) *DomainClone {
+	p := &DomainClone{Source: d, Target: target}
+	for _, a := range d.Accounts {
+		if include(a.Username) {
+			p.Accounts = append(p.Accounts, a)
+		}
+	}
+	for _, a := range d.Aliases {
+		if include(a.Name) {
+			p.Aliases = append(p.Aliases, a)
+		}
+	}
+	return p
+}
+
+// Rewrite returns the address in the target domain for an address in the source domain
+// whose account or alias is copied. Other addresses are returned unchanged.
+func (p *DomainClone) Rewrite(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i < 0 || !strings.EqualFold(email[i+1:], p.Source.Name) {
+		return email
+	}
+	name := email[:i]
+	for _, a := range p.Accounts {
+		if strings.EqualFold(a.Username, name) {
+			return fmt.Sprintf("%s@%s", name, p.Target)
+		}
+	}
+	for _, a := range p.Aliases {
+		if strings.EqualFold(a.Name, name) {
+			return fmt.Sprintf("%s@%s", name, p.Target)
+		}
+	}
+	return email
+}
+
+// Describe returns a line for each of the objects created by the clone.
+func (p *DomainClone) Describe() []string {
+	lines := []string{fmt.Sprintf("create domain %s", p.Target)}
+	for _, a := range p.Accounts {
+		email := fmt.Sprintf("%s@%s", a.Username, p.Target)
+		lines = append(lines, fmt.Sprintf("create account %s%s", email, DisabledSuffix(a.Enabled)))
+		if bcc := a.SenderBcc; bcc != nil {
+			lines = append(lines, fmt.Sprintf("create sender-bcc %s -> %s%s", email, p.Rewrite(bcc.Email), DisabledSuffix(bcc.Enabled)))
+		}
+		if bcc := a.RecipientBcc; bcc != nil {
+			lines = append(lines, fmt.Sprintf("create recipient-bcc %s -> %s%s", email, p.Rewrite(bcc.Email), DisabledSuffix(bcc.Enabled)))
+		}
+	}
+	for _, a := range p.Aliases {
+		lines = append(lines, fmt.Sprintf("create alias %s@%s -> %s%s", a.Name, p.Target, p.Rewrite(a.Email), DisabledSuffix(a.Enabled)))
+	}
+	return lines
+}
+
+// CloneDomain carries out the clone. The passwords of the new accounts are created with
+// the generator and passed to save once all objects are created, so no credentials are
+// saved for accounts which are rolled back. Accounts and aliases are
+// created concurrently with the client's bulk executor. The enabled aliases are refused
+// with an *AliasChainError if they would create a forwarding loop or exceed the maximum
+// alias chain depth. If a step fails, the completed steps are rolled back.
+func (c *Client) CloneDomain(p *DomainClone, generator *PasswordGenerator, save func(email, password string) error) error {
+	tx := NewTransaction()
+	err := tx.Do(fmt.Sprintf("create domain %s", p.Target),
+		func() error {
+			if err := c.Domains.Create(p.Target); err != nil {
+				return err
+			}
+			if !p.Source.Enabled {
+				return c.Domains.Disable(p.Target)
+			}
+			return nil
+		},
+		func() error {
+			return c.Domains.Delete(p.Target)
+		})
+	if err != nil {
+		return err
+	}
+
+	usernames := make([]string, len(p.Accounts))
+	for i, a := range p.Accounts {
+		usernames[i] = a.Username
+	}
+	credentials := make([]credential, len(p.Accounts))
+	err = c.Bulk.Run("Creating accounts", usernames, func(i int) error {
+		a := p.Accounts[i]
+		password, err := generator.Generate(a.Username, p.Target)
+		if err != nil {
+			return err
+		}
+		credentials[i] = credential{email: fmt.Sprintf("%s@%s", a.Username, p.Target), password: password}
+		return c.cloneAccount(tx, p, a, password)
+	})
+	if err != nil {
+		return tx.Rollback(fmt.Sprintf("clone the accounts of %s", p.Source.Name), err)
+	}
+
+	// The aliases are created concurrently, so all of them are checked before the first
+	// one is created.
+	checker := c.Aliases.newAliasChecker()
+	for _, a := range p.Aliases {
+		if !a.Enabled {
+			continue
+		}
+		if err := checker.check(p.Target, a.Name, p.Rewrite(a.Email)); err != nil {
+			return tx.Rollback(fmt.Sprintf("clone the aliases of %s", p.Source.Name), err)
+		}
+	}
+
+	names := make([]string, len(p.Aliases))
+	for i, a := range p.Aliases {
+		names[i] = fmt.Sprintf("%s -> %s", a.Name, a.Email)
+	}
+	err = c.Bulk.Run("Creating aliases", names, func(i int) error {
+		a := p.Aliases[i]
+		email := p.Rewrite(a.Email)
+		if err := c.Aliases.create(p.Target, a.Name, email); err != nil {
+			return err
+		}
+		tx.Completed(fmt.Sprintf("create alias %s@%s -> %s", a.Name, p.Target, email), func() error {
+			return c.Aliases.Delete(p.Target, a.Name, email)
+		})
+		if !a.Enabled {
+			return c.Aliases.Disable(p.Target, a.Name, email)
+		}
+		return nil
+	})
+	if err != nil {
+		return tx.Rollback(fmt.Sprintf("clone the aliases of %s", p.Source.Name), err)
+	}
+	return saveCredentials(tx, credentials, save)
+}
+
+// credential is the generated password of a new account.
+type credential struct {
+	email    string
+	password string
+}
+
+// saveCredentials passes the credentials to save once all steps of the transaction have
+// succeeded. If one of them can't be saved, the transaction is rolled back, so there are
+// no accounts with unknown passwords.
+func saveCredentials(tx *Transaction, credentials []credential, save func(email, password string) error) error {
+	for _, cr := range credentials {
+		if err := save(cr.email, cr.password); err != nil {
+			return tx.Rollback(fmt.Sprintf("save the password of %s", cr.email), err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) cloneAccount(tx *Transaction, p *DomainClone, a *AccountState, password string) error {
+	if err := c.Accounts.Create(p.Target, a.Username, password); err != nil {
+		return err
+	}
+	email := fmt.Sprintf("%s@%s", a.Username, p.Target)
+	tx.Completed(fmt.Sprintf("create account %s", email), func() error {
+		return c.Accounts.Delete(p.Target, a.Username)
+	})
+	if !a.Enabled {
+		if err := c.Accounts.Disable(p.Target, a.Username); err != nil {
+			return err
+		}
+	}
+
+	bccs := []struct {
+		service BccService
+		bcc     *Bcc
+	}{
+		{c.OutputBccs, a.SenderBcc},
+		{c.InputBccs, a.RecipientBcc},
+	}
+	for _, b := range bccs {
+		if b.bcc == nil {
+			continue
+		}
+		// The BCCs are removed together with the account when rolling back.
+		if err := b.service.Create(p.Target, a.Username, p.Rewrite(b.bcc.Email)); err != nil {
+			return err
+		}
+		if !b.bcc.Enabled {
+			if err := b.service.Disable(p.Target, a.Username); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
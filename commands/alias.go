@@ -17,7 +17,8 @@ func CreateAliasCommand() *Command {
 		},
 	}
 
-	BuildCommand(c, listAliases, "list <domain-name> [<name>]", "List all aliases", ArgsRangeOption(1, 2), AliasOption("l"))
+	listCmd := BuildCommand(c, listAliases, "list <domain-name> [<name>]", "List all aliases", ArgsRangeOption(1, 2), AliasOption("l"))
+	addListFilterFlags(listCmd)
 	BuildCommand(c, showAlias, "show <domain-name> <name> <recipient-email-address>", "Show specific alias", ArgsOption(3), AliasOption("s"))
 	BuildCommand(c, addAlias, "add <domain-name> <name> <recipient-email-address>", "Add a new alias", ArgsOption(3), AliasOption("a"))
 	BuildCommand(c, deleteAlias, "delete <domain-name> <name> [<recipient-email-address>]", "Delete alias(es)", ArgsRangeOption(2, 3), AliasOption("rm"))
@@ -36,11 +37,18 @@ func listAliases(client *emailctl.Client, args []string) error {
 }
 
 func listForDomain(client *emailctl.Client, args []string) error {
+	filter, err := loadListFilter()
+	if err != nil {
+		return err
+	}
 	domain := args[0]
 	aliases, err := client.Aliases.List(domain)
 	if err != nil {
 		return err
 	}
+	if aliases, err = filter.Aliases(aliases); err != nil {
+		return err
+	}
 
 	fmt.Printf("Aliases for '%s':\n", domain)
 	fmt.Printf("%-5s%-30s%-30s%-10s%-12s%-12s\n", "ID", "Alias", "Email Address", "Enabled", "Created", "Updated")
@@ -52,11 +60,18 @@ func listForDomain(client *emailctl.Client, args []string) error {
 }
 
 func listForAlias(client *emailctl.Client, args []string) error {
+	filter, err := loadListFilter()
+	if err != nil {
+		return err
+	}
 	domain, alias := args[0], args[1]
 	aliases, err := client.Aliases.Get(domain, alias)
 	if err != nil {
 		return err
 	}
+	if aliases, err = filter.Aliases(aliases); err != nil {
+		return err
+	}
 
 	fmt.Printf("Aliases for '%s@%s':\n", alias, domain)
 	fmt.Printf("%-5s%-30s%-30s%-10s%-12s%-12s\n", "ID", "Alias", "Email Address", "Enabled", "Created", "Updated")
@@ -93,8 +108,24 @@ func deleteAlias(client *emailctl.Client, args []string) error {
 	domain, alias := args[0], args[1]
 	if len(args) == 3 {
 		email := args[2]
+		details := fmt.Sprintf("Alias '%s@%s' will no longer forward to '%s'.", alias, domain, email)
+		if err := confirm("Delete alias?", details); err != nil {
+			return err
+		}
 		return client.Aliases.Delete(domain, alias, email)
 	}
+
+	aliases, err := client.Aliases.Get(domain, alias)
+	if err != nil {
+		return err
+	}
+	details := []string{fmt.Sprintf("Alias '%s@%s' will be removed for all %d recipients:", alias, domain, len(aliases))}
+	for _, a := range aliases {
+		details = append(details, fmt.Sprintf("  %s", a.Email))
+	}
+	if err := confirm("Delete alias?", details...); err != nil {
+		return err
+	}
 	return client.Aliases.DeleteAll(domain, alias)
 }
 
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lyubenblagoev/emailctl"
+	"github.com/spf13/cobra"
+)
+
+var auditFilter struct {
+	resource  string
+	operator  string
+	operation string
+	since     string
+	until     string
+}
+
+// CreateAuditCommand creates an audit command with all its sub-commands.
+func CreateAuditCommand() *Command {
+	c := &Command{
+		Command: &cobra.Command{
+			Use:   "audit",
+			Short: "Audit commands",
+			Long:  "Audit is used to access the local journal of changes made with emailctl",
+		},
+	}
+
+	log := BuildCommand(c, showAuditLog, "log", "Show the journal of changes made with emailctl", AliasOption("l"))
+	log.Flags().StringVar(&auditFilter.resource, "resource", "", "only show changes to resources containing this domain, email address or API path")
+	log.Flags().StringVar(&auditFilter.operator, "operator", "", "only show changes made by this operating system user or login")
+	log.Flags().StringVar(&auditFilter.operation, "operation", "", "only show operations containing this text, e.g. 'delete' or 'update account'")
+	log.Flags().StringVar(&auditFilter.since, "since", "", "only show changes made at or after this time (date, RFC 3339 time or duration such as 24h or 7d)")
+	log.Flags().StringVar(&auditFilter.until, "until", "", "only show changes made before this time (date, RFC 3339 time or duration such as 24h or 7d)")
+
+	return c
+}
+
+func showAuditLog(client *emailctl.Client, args []string) error {
+	journal, err := emailctl.LoadAuditJournal()
+	if err != nil {
+		return err
+	}
+	if journal == nil {
+		return fmt.Errorf("the audit journal is disabled")
+	}
+
+	filter := &emailctl.AuditFilter{
+		Resource:  auditFilter.resource,
+		Operator:  auditFilter.operator,
+		Operation: auditFilter.operation,
+	}
+	if filter.Since, err = parseTime(auditFilter.since); err != nil {
+		return err
+	}
+	if filter.Until, err = parseTime(auditFilter.until); err != nil {
+		return err
+	}
+
+	records, err := journal.Read(filter)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-14s%-22s%-20s%-24s%-40s%-10s\n", "ID", "Time", "Operator", "Operation", "Target", "Result")
+	for _, r := range records {
+		result := "ok"
+		if !r.Succeeded() {
+			result = "failed"
+		}
+		operator := r.User
+		if r.Login != "" {
+			operator = fmt.Sprintf("%s (%s)", r.User, r.Login)
+		}
+		fmt.Printf("%-14s%-22s%-20s%-24s%-40s%-10s\n", r.ID, r.Time.Local().Format("2006-01-02 15:04:05"), operator, r.Operation, r.Target, result)
+	}
+
+	return nil
+}
+
+// parseTime parses the value of a time flag with emailctl.ParseTime. An empty string
+// yields the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, _, err := emailctl.ParseTime(s)
+	return t, err
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	assumeYes bool
+	force     bool
+)
+
+// confirm asks the user to confirm an action described by 'details'. It returns nil if
+// the action is confirmed, --yes was given or nothing will be changed because of --dry-run,
+// and an error otherwise.
+func confirm(question string, details ...string) error {
+	if assumeYes {
+		return nil
+	}
+	if dryRun {
+		for _, d := range details {
+			fmt.Println(d)
+		}
+		return nil
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("refusing to proceed without confirmation, use --yes to confirm in non-interactive mode")
+	}
+
+	for _, d := range details {
+		fmt.Println(d)
+	}
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errors.New("aborted")
+}
+
+// checkDomainProtected returns an error if the domain is listed in the 'protected.domains'
+// setting and --force was not given.
+func checkDomainProtected(domain string) error {
+	if force {
+		return nil
+	}
+	if matchesProtected(domain, viper.GetStringSlice("protected.domains")) {
+		return fmt.Errorf("domain '%s' is protected, use --force to override", domain)
+	}
+	return nil
+}
+
+// checkAccountProtected returns an error if the account is listed in the 'protected.accounts'
+// setting and --force was not given.
+func checkAccountProtected(domain, username string) error {
+	if force {
+		return nil
+	}
+	email := fmt.Sprintf("%s@%s", username, domain)
+	if matchesProtected(email, viper.GetStringSlice("protected.accounts")) {
+		return fmt.Errorf("account '%s' is protected, use --force to override", email)
+	}
+	return nil
+}
+
+func matchesProtected(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		if matched, err := path.Match(strings.ToLower(p), name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
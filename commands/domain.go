@@ -1,12 +1,28 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/lyubenblagoev/emailctl"
 	"github.com/spf13/cobra"
 )
 
+var (
+	domainRenamePropagate bool
+	domainRenameKeepOld   bool
+	cloneInclude          []string
+	cloneExclude          []string
+	cloneCredentialsFile  string
+	cloneCredentialsDir   string
+	clonePasswordMode     string
+	mergeCredentialsFile  string
+	mergeCredentialsDir   string
+	mergePasswordMode     string
+)
+
 // CreateDomainCommand creates a domain command with all its sub-commands.
 func CreateDomainCommand() *Command {
 	c := &Command{
@@ -17,22 +33,46 @@ func CreateDomainCommand() *Command {
 		},
 	}
 
-	BuildCommand(c, listDomains, "list", "List all domains", AliasOption("l"))
+	listCmd := BuildCommand(c, listDomains, "list", "List all domains", AliasOption("l"))
+	addListFilterFlags(listCmd)
 	BuildCommand(c, showDomain, "show <domain-name>", "Show specific domain", ArgsOption(1), AliasOption("s"))
 	BuildCommand(c, addDomain, "add <domain-name>", "Add a new domain", ArgsOption(1), AliasOption("a"))
-	BuildCommand(c, deleteDomain, "delete <domain-name>", "Delete a domain", ArgsOption(1), AliasOption("rm"))
-	BuildCommand(c, renameDomain, "rename <domain-name> <new-domain-name>", "Rename a domain", ArgsOption(2), AliasOption("r"))
-	BuildCommand(c, disableDomain, "disable <domain-name>", "Disable a domain", ArgsOption(1), AliasOption("d"))
+	deleteCmd := BuildCommand(c, deleteDomain, "delete <domain-name>", "Delete a domain", ArgsOption(1), AliasOption("rm"))
+	deleteCmd.Flags().BoolVar(&force, "force", false, "delete the domain even if it is protected")
+	renameCmd := BuildCommand(c, renameDomain, "rename <domain-name> <new-domain-name>", "Rename a domain", ArgsOption(2), AliasOption("r"))
+	renameCmd.Flags().BoolVar(&domainRenamePropagate, "propagate", false, "also change the aliases and BCCs referencing addresses in the old domain to the new domain")
+	renameCmd.Flags().BoolVar(&domainRenameKeepOld, "keep-old", false, "keep the old domain with aliases forwarding all its addresses to the new domain")
+	disableCmd := BuildCommand(c, disableDomain, "disable <domain-name>", "Disable a domain", ArgsOption(1), AliasOption("d"))
+	disableCmd.Flags().BoolVar(&force, "force", false, "disable the domain even if it is protected")
 	BuildCommand(c, enableDomain, "enable <domain-name>", "Enable a domain", ArgsOption(1), AliasOption("e"))
+	BuildCommand(c, domainTree, "tree <domain-name>", "Show the accounts, BCCs and aliases of a domain", ArgsOption(1), AliasOption("t"))
+	cloneCmd := BuildCommand(c, cloneDomain, "clone <domain-name> <new-domain-name>", "Copy the accounts, aliases and BCCs of a domain into a new domain", ArgsOption(2))
+	cloneCmd.Flags().StringSliceVar(&cloneInclude, "include", nil, "only copy accounts and aliases whose name matches one of the glob patterns")
+	cloneCmd.Flags().StringSliceVar(&cloneExclude, "exclude", nil, "don't copy accounts and aliases whose name matches one of the glob patterns")
+	cloneCmd.Flags().StringVar(&cloneCredentialsFile, "credentials-file", "", "write the credentials of the new accounts to this encrypted file")
+	cloneCmd.Flags().StringVar(&cloneCredentialsDir, "credentials-dir", "", "write the credentials of each new account to a separate encrypted file in this directory")
+	cloneCmd.Flags().StringVar(&clonePasswordMode, "mode", string(emailctl.RandomPasswordMode), "password generation mode: random or diceware")
+	mergeCmd := BuildCommand(c, mergeDomain, "merge <domain-name> <into-domain-name>", "Merge the accounts and aliases of a domain into another domain", ArgsOption(2))
+	mergeCmd.Flags().StringVar(&mergeCredentialsFile, "credentials-file", "", "write the credentials of the moved accounts to this encrypted file")
+	mergeCmd.Flags().StringVar(&mergeCredentialsDir, "credentials-dir", "", "write the credentials of each moved account to a separate encrypted file in this directory")
+	mergeCmd.Flags().StringVar(&mergePasswordMode, "mode", string(emailctl.RandomPasswordMode), "password generation mode: random or diceware")
+	mergeCmd.Flags().BoolVar(&force, "force", false, "merge the domain even if it is protected")
 
 	return c
 }
 
 func listDomains(client *emailctl.Client, args []string) error {
+	filter, err := loadListFilter()
+	if err != nil {
+		return err
+	}
 	domains, err := client.Domains.List()
 	if err != nil {
 		return err
 	}
+	if domains, err = filter.Domains(domains); err != nil {
+		return err
+	}
 
 	fmt.Printf("Domains:\n")
 	fmt.Printf("%-5s%-30s%-10s%-12s%-12s\n", "ID", "Name", "Enabled", "Created", "Updated")
@@ -59,6 +99,48 @@ func showDomain(client *emailctl.Client, args []string) error {
 	return nil
 }
 
+func domainTree(client *emailctl.Client, args []string) error {
+	name := args[0]
+	state, err := client.LoadState(&emailctl.StateOptions{Domains: []string{name}})
+	if err != nil {
+		return err
+	}
+	domain := state.Domains[0]
+	if output == jsonOutput {
+		return printJSON(domain)
+	}
+
+	accounts := &treeNode{label: fmt.Sprintf("Accounts (%d)", len(domain.Accounts))}
+	for _, a := range domain.Accounts {
+		n := &treeNode{label: fmt.Sprintf("%s@%s%s", a.Username, domain.Name, emailctl.DisabledSuffix(a.Enabled))}
+		if bcc := a.SenderBcc; bcc != nil {
+			n.children = append(n.children, &treeNode{label: fmt.Sprintf("sender-bcc: %s%s", bcc.Email, emailctl.DisabledSuffix(bcc.Enabled))})
+		}
+		if bcc := a.RecipientBcc; bcc != nil {
+			n.children = append(n.children, &treeNode{label: fmt.Sprintf("recipient-bcc: %s%s", bcc.Email, emailctl.DisabledSuffix(bcc.Enabled))})
+		}
+		accounts.children = append(accounts.children, n)
+	}
+
+	aliases := &treeNode{}
+	byName := make(map[string]*treeNode)
+	for _, a := range domain.Aliases {
+		key := strings.ToLower(a.Name)
+		n, ok := byName[key]
+		if !ok {
+			n = &treeNode{label: fmt.Sprintf("%s@%s", a.Name, domain.Name)}
+			byName[key] = n
+			aliases.children = append(aliases.children, n)
+		}
+		n.children = append(n.children, &treeNode{label: a.Email + emailctl.DisabledSuffix(a.Enabled)})
+	}
+	aliases.label = fmt.Sprintf("Aliases (%d)", len(aliases.children))
+
+	root := &treeNode{label: domain.Name + emailctl.DisabledSuffix(domain.Enabled), children: []*treeNode{accounts, aliases}}
+	printTree(os.Stdout, []*treeNode{root})
+	return nil
+}
+
 func addDomain(client *emailctl.Client, args []string) error {
 	name := args[0]
 	return client.Domains.Create(name)
@@ -66,16 +148,78 @@ func addDomain(client *emailctl.Client, args []string) error {
 
 func deleteDomain(client *emailctl.Client, args []string) error {
 	name := args[0]
+	if err := checkDomainProtected(name); err != nil {
+		return err
+	}
+
+	accounts, err := client.Accounts.List(name)
+	if err != nil {
+		return err
+	}
+	for _, a := range accounts {
+		if err := checkAccountProtected(name, a.Username); err != nil {
+			return err
+		}
+	}
+	aliases, err := client.Aliases.List(name)
+	if err != nil {
+		return err
+	}
+	details := fmt.Sprintf("Domain '%s' has %d accounts and %d aliases which will be removed.", name, len(accounts), len(aliases))
+	if err := confirm("Delete domain?", details); err != nil {
+		return err
+	}
+
 	return client.Domains.Delete(name)
 }
 
 func renameDomain(client *emailctl.Client, args []string) error {
 	oldName, newName := args[0], args[1]
-	return client.Domains.Rename(oldName, newName)
+	if !domainRenamePropagate && !domainRenameKeepOld {
+		return client.Domains.Rename(oldName, newName)
+	}
+
+	state, err := client.LoadState(&emailctl.StateOptions{SkipBccs: !domainRenamePropagate})
+	if err != nil {
+		return err
+	}
+	domain := state.FindDomain(oldName)
+	if domain == nil {
+		return fmt.Errorf("domain '%s' does not exist", oldName)
+	}
+
+	var refs []emailctl.Reference
+	if domainRenamePropagate {
+		refs = state.ReferencesToDomain(oldName)
+	}
+	var forward []string
+	if domainRenameKeepOld {
+		forward = domain.Addresses()
+	}
+
+	details := []string{fmt.Sprintf("Domain '%s' will be renamed to '%s'.", oldName, newName)}
+	if len(refs) > 0 {
+		details = append(details, fmt.Sprintf("The following aliases and BCCs will be changed to point to '%s':", newName))
+		for _, ref := range refs {
+			details = append(details, fmt.Sprintf("  %s", ref.String()))
+		}
+	}
+	if len(forward) > 0 {
+		details = append(details, fmt.Sprintf("Domain '%s' will be kept with aliases forwarding mail to '%s' for: %s",
+			oldName, newName, strings.Join(forward, ", ")))
+	}
+	if err := confirm("Rename domain?", details...); err != nil {
+		return err
+	}
+
+	return client.RenameDomainPropagate(oldName, newName, refs, forward)
 }
 
 func disableDomain(client *emailctl.Client, args []string) error {
 	domainName := args[0]
+	if err := checkDomainProtected(domainName); err != nil {
+		return err
+	}
 	return client.Domains.Disable(domainName)
 }
 
@@ -83,3 +227,175 @@ func enableDomain(client *emailctl.Client, args []string) error {
 	domainName := args[0]
 	return client.Domains.Enable(domainName)
 }
+
+func cloneDomain(client *emailctl.Client, args []string) error {
+	source, target := args[0], args[1]
+	if (cloneCredentialsFile == "") == (cloneCredentialsDir == "") {
+		return errors.New("exactly one of --credentials-file and --credentials-dir is required")
+	}
+
+	state, err := client.LoadState(nil)
+	if err != nil {
+		return err
+	}
+	domain := state.FindDomain(source)
+	if domain == nil {
+		return fmt.Errorf("domain '%s' does not exist", source)
+	}
+	if state.FindDomain(target) != nil {
+		return fmt.Errorf("domain '%s' already exists", target)
+	}
+
+	var filterErr error
+	clone := domain.PlanClone(target, func(name string) bool {
+		included, err := matchesAny(name, cloneInclude)
+		if err != nil {
+			filterErr = err
+			return false
+		}
+		excluded := false
+		if len(cloneExclude) > 0 {
+			excluded, err = matchesAny(name, cloneExclude)
+			if err != nil {
+				filterErr = err
+			}
+		}
+		return included && !excluded
+	})
+	if filterErr != nil {
+		return filterErr
+	}
+
+	details := []string{"The following objects will be created:"}
+	for _, line := range clone.Describe() {
+		details = append(details, fmt.Sprintf("  %s", line))
+	}
+	if err := confirm("Clone domain?", details...); err != nil {
+		return err
+	}
+
+	// Nothing is changed in dry-run mode, so there are no credentials to save.
+	var store credentialStore = discardCredentialStore{}
+	if !dryRun {
+		passphrase, err := emailctl.ReadAndConfirmSecret("Credentials passphrase: ", "Confirm passphrase: ")
+		if err != nil {
+			return err
+		}
+		store, err = newCredentialStore(cloneCredentialsFile, cloneCredentialsDir, []byte(passphrase))
+		if err != nil {
+			return err
+		}
+	}
+
+	emails := make([]string, len(clone.Accounts))
+	for i, a := range clone.Accounts {
+		emails[i] = fmt.Sprintf("%s@%s", a.Username, clone.Target)
+	}
+	if err := store.Reserve(emails); err != nil {
+		store.Close()
+		return fmt.Errorf("unable to write the credentials: %v", err)
+	}
+
+	generator := emailctl.NewPasswordGenerator(client.Accounts.PasswordPolicy())
+	generator.Mode = emailctl.PasswordMode(clonePasswordMode)
+	err = client.CloneDomain(clone, generator, store.Save)
+	if err := store.Close(); err != nil {
+		return fmt.Errorf("unable to write the credentials: %v", err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Copied %d accounts and %d aliases from '%s' to '%s'.\n", len(clone.Accounts), len(clone.Aliases), source, target)
+	return nil
+}
+
+func mergeDomain(client *emailctl.Client, args []string) error {
+	from, into := args[0], args[1]
+	if (mergeCredentialsFile == "") == (mergeCredentialsDir == "") {
+		return errors.New("exactly one of --credentials-file and --credentials-dir is required")
+	}
+	if err := checkDomainProtected(from); err != nil {
+		return err
+	}
+
+	state, err := client.LoadState(nil)
+	if err != nil {
+		return err
+	}
+	merge, err := state.PlanDomainMerge(from, into)
+	if err != nil {
+		return err
+	}
+	for _, m := range merge.Moves {
+		if err := checkAccountProtected(m.Domain, m.Username); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Merge plan for '%s' into '%s':\n", merge.From.Name, merge.Into.Name)
+	for _, line := range merge.Summary() {
+		fmt.Printf("  %s\n", line)
+	}
+	details := []string{"The following changes will be made:"}
+	for _, line := range merge.Describe() {
+		details = append(details, fmt.Sprintf("  %s", line))
+	}
+	if err := confirm("Merge domains?", details...); err != nil {
+		return err
+	}
+
+	// Nothing is changed in dry-run mode, so there are no credentials to save.
+	var store credentialStore = discardCredentialStore{}
+	if !dryRun {
+		passphrase, err := emailctl.ReadAndConfirmSecret("Credentials passphrase: ", "Confirm passphrase: ")
+		if err != nil {
+			return err
+		}
+		store, err = newCredentialStore(mergeCredentialsFile, mergeCredentialsDir, []byte(passphrase))
+		if err != nil {
+			return err
+		}
+	}
+
+	var emails []string
+	for _, m := range merge.Moves {
+		if !m.Existing {
+			emails = append(emails, m.NewEmail())
+		}
+	}
+	if err := store.Reserve(emails); err != nil {
+		store.Close()
+		return fmt.Errorf("unable to write the credentials: %v", err)
+	}
+
+	generator := emailctl.NewPasswordGenerator(client.Accounts.PasswordPolicy())
+	generator.Mode = emailctl.PasswordMode(mergePasswordMode)
+	err = client.MergeDomain(merge, generator, store.Save)
+	if err := store.Close(); err != nil {
+		return fmt.Errorf("unable to write the credentials: %v", err)
+	}
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+
+	after, err := client.LoadState(&emailctl.StateOptions{Domains: []string{merge.From.Name, merge.Into.Name}, SkipBccs: true})
+	if err != nil {
+		return err
+	}
+	collisions := len(merge.Collisions())
+	fmt.Printf("Merged '%s' into '%s': moved %d accounts and %d aliases, %d accounts forward to existing addresses.\n",
+		merge.From.Name, merge.Into.Name, len(merge.Moves)-collisions, len(merge.Aliases), collisions)
+	for _, d := range after.Domains {
+		enabled := 0
+		for _, a := range d.Accounts {
+			if a.Enabled {
+				enabled++
+			}
+		}
+		fmt.Printf("  '%s' now has %d accounts (%d enabled) and %d aliases.\n", d.Name, len(d.Accounts), enabled, len(d.Aliases))
+	}
+	return nil
+}
@@ -17,6 +17,9 @@ var EmailctlVersion = emailctl.Version{
 }
 
 var cfgFile string
+var dryRun bool
+var concurrency int
+var rateLimit float64
 var client *emailctl.Client
 
 // emailctlCommand represents the base command when called without any subcommands
@@ -36,6 +39,11 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 	emailctlCommand.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.emailctl.yaml)")
+	emailctlCommand.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print mutating API requests instead of sending them")
+	emailctlCommand.PersistentFlags().IntVar(&concurrency, "concurrency", 4, "maximum number of concurrent requests in bulk operations")
+	emailctlCommand.PersistentFlags().Float64Var(&rateLimit, "rate-limit", 0, "maximum number of bulk operation items started per second (0 means no limit)")
+	emailctlCommand.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "answer yes to all confirmation prompts")
+	emailctlCommand.PersistentFlags().StringVarP(&output, "output", "o", textOutput, "output format of reports: text or json")
 	initCommands()
 }
 
@@ -59,6 +67,7 @@ func initConfig() {
 	viper.SetDefault("https", false)
 
 	checkErr(viper.ReadInConfig())
+	checkErr(checkOutputFormat())
 
 	initClient()
 }
@@ -71,10 +80,39 @@ func initCommands() {
 	emailctlCommand.AddCommand(CreateVersionCommand())
 	emailctlCommand.AddCommand(CreateSenderBccCommand())
 	emailctlCommand.AddCommand(CreateRecipientBccCommand())
+	emailctlCommand.AddCommand(CreatePasswordCommand())
+	emailctlCommand.AddCommand(CreateAuditCommand())
+	emailctlCommand.AddCommand(CreateUndoCommand())
+	emailctlCommand.AddCommand(CreateResolveCommand())
+	emailctlCommand.AddCommand(CreateReferencesCommand())
+	emailctlCommand.AddCommand(CreateLintCommand())
+	emailctlCommand.AddCommand(CreateGraphCommand())
+	emailctlCommand.AddCommand(CreateSearchCommand())
+	emailctlCommand.AddCommand(CreateStatsCommand())
+	emailctlCommand.AddCommand(CreateReportCommand())
 }
 
 func initClient() {
-	var err error
-	client, err = emailctl.NewClient()
+	var options []emailctl.ClientOption
+	journal, err := emailctl.LoadAuditJournal()
+	checkErr(err)
+	if journal != nil {
+		options = append(options, emailctl.AuditOption(journal))
+	}
+	if dryRun {
+		options = append(options, emailctl.DryRunOption(os.Stdout))
+	}
+
+	executor := emailctl.LoadExecutor()
+	if emailctlCommand.PersistentFlags().Changed("concurrency") {
+		executor.Concurrency = concurrency
+	}
+	if emailctlCommand.PersistentFlags().Changed("rate-limit") {
+		executor.RateLimit = rateLimit
+	}
+	executor.Progress = os.Stderr
+	options = append(options, emailctl.ExecutorOption(executor))
+
+	client, err = emailctl.NewClient(options...)
 	checkErr(err)
 }
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -8,9 +9,25 @@ import (
 	"github.com/lyubenblagoev/goprsc"
 )
 
+// exitCodeError makes checkErr exit with the given code without printing anything. It is
+// returned by commands which report their result in their output, like lint.
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 func checkErr(err error) {
+	if e, ok := err.(*exitCodeError); ok {
+		// The command itself succeeded, so the refreshed tokens are kept.
+		SaveAuth(client.GetLogin(), client.GetAuthToken(), client.GetRefreshToken())
+		os.Exit(e.code)
+	}
 	if err != nil {
-		if e, ok := err.(*goprsc.ErrorResponse); ok {
+		var e *goprsc.ErrorResponse
+		if errors.As(err, &e) {
 			statusCode := e.Response.StatusCode
 			if statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden {
 				CleanAuth()
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/lyubenblagoev/emailctl"
+)
+
+var listFilterFlags struct {
+	enabled       bool
+	disabled      bool
+	createdBefore string
+	createdAfter  string
+	updatedSince  string
+	nameMatch     string
+	expression    string
+}
+
+// addListFilterFlags adds the flags selecting the listed objects to a list command.
+func addListFilterFlags(c *Command) {
+	c.Flags().BoolVar(&listFilterFlags.enabled, "enabled", false, "only list enabled entries")
+	c.Flags().BoolVar(&listFilterFlags.disabled, "disabled", false, "only list disabled entries")
+	c.Flags().StringVar(&listFilterFlags.createdBefore, "created-before", "", "only list entries created before this time (date, RFC 3339 time or duration such as 24h or 7d)")
+	c.Flags().StringVar(&listFilterFlags.createdAfter, "created-after", "", "only list entries created after this time (date, RFC 3339 time or duration such as 24h or 7d)")
+	c.Flags().StringVar(&listFilterFlags.updatedSince, "updated-since", "", "only list entries updated at or after this time (date, RFC 3339 time or duration such as 24h or 7d)")
+	c.Flags().StringVar(&listFilterFlags.nameMatch, "name-match", "", "only list entries whose name matches this regular expression")
+	c.Flags().StringVar(&listFilterFlags.expression, "filter", "", "only list entries matching this expression, e.g. 'enabled == false && created < 2024-01-01'")
+}
+
+// loadListFilter builds the list filter from the list filter flags.
+func loadListFilter() (*emailctl.ListFilter, error) {
+	filter := &emailctl.ListFilter{}
+	if listFilterFlags.enabled && listFilterFlags.disabled {
+		return nil, errors.New("--enabled and --disabled can't be used together")
+	}
+	if listFilterFlags.enabled || listFilterFlags.disabled {
+		enabled := listFilterFlags.enabled
+		filter.Enabled = &enabled
+	}
+
+	var err error
+	if filter.CreatedBefore, err = parseTime(listFilterFlags.createdBefore); err != nil {
+		return nil, err
+	}
+	if filter.CreatedAfter, err = parseTime(listFilterFlags.createdAfter); err != nil {
+		return nil, err
+	}
+	if filter.UpdatedSince, err = parseTime(listFilterFlags.updatedSince); err != nil {
+		return nil, err
+	}
+	if listFilterFlags.nameMatch != "" {
+		if filter.NameMatch, err = regexp.Compile(listFilterFlags.nameMatch); err != nil {
+			return nil, fmt.Errorf("invalid regular expression '%s': %v", listFilterFlags.nameMatch, err)
+		}
+	}
+	if listFilterFlags.expression != "" {
+		if filter.Expression, err = emailctl.ParseFilter(listFilterFlags.expression); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
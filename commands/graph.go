@@ -0,0 +1,224 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lyubenblagoev/emailctl"
+)
+
+// Graph formats.
+const (
+	dotFormat     = "dot"
+	mermaidFormat = "mermaid"
+)
+
+var (
+	graphDomain  string
+	graphFormat  string
+	graphCluster bool
+)
+
+// CreateGraphCommand creates the graph command.
+func CreateGraphCommand() *Command {
+	c := BuildCommand(nil, exportGraph, "graph", "Export the forwarding graph as Graphviz DOT or Mermaid", ArgsOption(0))
+	c.Long = "Graph renders the domains, accounts and aliases and the alias and BCC relations between them " +
+		"as a Graphviz DOT or Mermaid flowchart. Disabled domains, accounts, aliases and BCCs are drawn dashed."
+	c.Flags().StringVar(&graphDomain, "domain", "", "only include the given domain and the addresses connected to it")
+	c.Flags().StringVarP(&graphFormat, "format", "f", dotFormat, "graph format: dot or mermaid")
+	c.Flags().BoolVar(&graphCluster, "cluster", false, "group the addresses of each domain")
+	return c
+}
+
+func exportGraph(client *emailctl.Client, args []string) error {
+	if graphFormat != dotFormat && graphFormat != mermaidFormat {
+		return fmt.Errorf("invalid graph format '%s', must be %s or %s", graphFormat, dotFormat, mermaidFormat)
+	}
+
+	state, err := client.LoadState(nil)
+	if err != nil {
+		return err
+	}
+	g := state.Graph()
+	if graphDomain != "" {
+		if state.FindDomain(graphDomain) == nil {
+			return fmt.Errorf("domain '%s' does not exist", graphDomain)
+		}
+		g = g.FilterDomain(graphDomain)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if graphFormat == mermaidFormat {
+		writeMermaid(w, g, graphCluster)
+	} else {
+		writeDOT(w, g, graphCluster)
+	}
+	return w.Flush()
+}
+
+// graphClusters groups the nodes by domain in the order the domains first appear. Nodes
+// of external addresses are returned separately. Domain nodes are left out when
+// clustering, as the clusters represent them.
+func graphClusters(g *emailctl.ForwardingGraph) (domains []string, clusters map[string][]*emailctl.GraphNode, external []*emailctl.GraphNode) {
+	clusters = make(map[string][]*emailctl.GraphNode)
+	for _, n := range g.Nodes {
+		switch {
+		case n.Domain == "":
+			external = append(external, n)
+		case n.Kind == emailctl.DomainNode:
+		default:
+			if _, ok := clusters[n.Domain]; !ok {
+				domains = append(domains, n.Domain)
+			}
+			clusters[n.Domain] = append(clusters[n.Domain], n)
+		}
+	}
+	return domains, clusters, external
+}
+
+var dotShapes = map[string]string{
+	emailctl.DomainNode:   "folder",
+	emailctl.AccountNode:  "box",
+	emailctl.AliasNode:    "ellipse",
+	emailctl.ExternalNode: "note",
+	emailctl.UnknownNode:  "octagon",
+}
+
+func writeDOT(w io.Writer, g *emailctl.ForwardingGraph, cluster bool) {
+	fmt.Fprintln(w, "digraph emailctl {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [fontname=\"Helvetica\"];")
+	fmt.Fprintln(w, "  edge [fontname=\"Helvetica\", fontsize=10];")
+
+	node := func(indent string, n *emailctl.GraphNode) {
+		style := ""
+		if !n.Enabled {
+			style = ", style=dashed, color=gray, fontcolor=gray"
+		}
+		fmt.Fprintf(w, "%s%s [label=%s, shape=%s%s];\n", indent, strconv.Quote(n.ID), strconv.Quote(n.Label), dotShapes[n.Kind], style)
+	}
+	if cluster {
+		domains, clusters, external := graphClusters(g)
+		for i, d := range domains {
+			fmt.Fprintf(w, "  subgraph cluster_%d {\n", i)
+			fmt.Fprintf(w, "    label=%s;\n", strconv.Quote(d))
+			for _, n := range clusters[d] {
+				node("    ", n)
+			}
+			fmt.Fprintln(w, "  }")
+		}
+		for _, n := range external {
+			node("  ", n)
+		}
+	} else {
+		for _, n := range g.Nodes {
+			node("  ", n)
+		}
+	}
+
+	for _, e := range g.Edges {
+		if cluster && e.Kind == emailctl.MemberEdge {
+			continue
+		}
+		var attrs []string
+		switch e.Kind {
+		case emailctl.MemberEdge:
+			attrs = append(attrs, "arrowhead=none", "color=lightgray")
+		case emailctl.SenderBccReference:
+			attrs = append(attrs, "label=\"sender bcc\"", "color=blue")
+		case emailctl.RecipientBccReference:
+			attrs = append(attrs, "label=\"recipient bcc\"", "color=darkgreen")
+		}
+		if !e.Enabled {
+			attrs = append(attrs, "style=dashed", "color=gray")
+		}
+		fmt.Fprintf(w, "  %s -> %s", strconv.Quote(e.From), strconv.Quote(e.To))
+		if len(attrs) > 0 {
+			fmt.Fprintf(w, " [%s]", strings.Join(attrs, ", "))
+		}
+		fmt.Fprintln(w, ";")
+	}
+	fmt.Fprintln(w, "}")
+}
+
+var mermaidShapes = map[string][2]string{
+	emailctl.DomainNode:   {"[(", ")]"},
+	emailctl.AccountNode:  {"[", "]"},
+	emailctl.AliasNode:    {"([", "])"},
+	emailctl.ExternalNode: {"{{", "}}"},
+	emailctl.UnknownNode:  {"((", "))"},
+}
+
+func writeMermaid(w io.Writer, g *emailctl.ForwardingGraph, cluster bool) {
+	// Mermaid IDs can't contain most punctuation, so the nodes are numbered.
+	ids := make(map[string]string)
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+	quote := func(s string) string {
+		return "\"" + strings.Replace(s, "\"", "#quot;", -1) + "\""
+	}
+
+	fmt.Fprintln(w, "flowchart LR")
+	var disabled []string
+	node := func(indent string, n *emailctl.GraphNode) {
+		shape := mermaidShapes[n.Kind]
+		fmt.Fprintf(w, "%s%s%s%s%s\n", indent, ids[n.ID], shape[0], quote(n.Label), shape[1])
+		if !n.Enabled {
+			disabled = append(disabled, ids[n.ID])
+		}
+	}
+	if cluster {
+		domains, clusters, external := graphClusters(g)
+		for i, d := range domains {
+			fmt.Fprintf(w, "  subgraph d%d[%s]\n", i, quote(d))
+			for _, n := range clusters[d] {
+				node("    ", n)
+			}
+			fmt.Fprintln(w, "  end")
+		}
+		for _, n := range external {
+			node("  ", n)
+		}
+	} else {
+		for _, n := range g.Nodes {
+			node("  ", n)
+		}
+	}
+
+	for _, e := range g.Edges {
+		if cluster && e.Kind == emailctl.MemberEdge {
+			continue
+		}
+		var label string
+		switch e.Kind {
+		case emailctl.SenderBccReference:
+			label = "sender bcc"
+		case emailctl.RecipientBccReference:
+			label = "recipient bcc"
+		}
+		var arrow string
+		switch {
+		case e.Kind == emailctl.MemberEdge:
+			arrow = "---"
+		case !e.Enabled && label != "":
+			arrow = fmt.Sprintf("-. %s .->", quote(label))
+		case !e.Enabled:
+			arrow = "-.->"
+		case label != "":
+			arrow = fmt.Sprintf("-- %s -->", quote(label))
+		default:
+			arrow = "-->"
+		}
+		fmt.Fprintf(w, "  %s %s %s\n", ids[e.From], arrow, ids[e.To])
+	}
+
+	if len(disabled) > 0 {
+		fmt.Fprintln(w, "  classDef disabled stroke-dasharray:5 5,color:#999,stroke:#999")
+		fmt.Fprintf(w, "  class %s disabled\n", strings.Join(disabled, ","))
+	}
+}
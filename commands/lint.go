@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/lyubenblagoev/emailctl"
+)
+
+// CreateLintCommand creates the lint command.
+func CreateLintCommand() *Command {
+	c := BuildCommand(nil, lint, "lint", "Check the server configuration for problems", ArgsOption(0))
+	c.Long = "Lint loads all domains, accounts, aliases and BCCs and reports aliases pointing to unknown or " +
+		"disabled local addresses, aliases shadowing accounts, alias loops, BCCs to the account itself or to " +
+		"disabled accounts, enabled accounts in disabled domains and alias recipients differing only in case. " +
+		"The exit code is 1 if problems were found."
+	return c
+}
+
+func lint(client *emailctl.Client, args []string) error {
+	state, err := client.LoadState(nil)
+	if err != nil {
+		return err
+	}
+	findings := state.Lint()
+
+	if output == jsonOutput {
+		if findings == nil {
+			findings = []emailctl.LintFinding{}
+		}
+		if err := printJSON(findings); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range findings {
+			fmt.Println(f.String())
+		}
+		if len(findings) == 0 {
+			fmt.Println("No problems found.")
+		} else {
+			fmt.Printf("%d problems found.\n", len(findings))
+		}
+	}
+
+	if len(findings) > 0 {
+		return &exitCodeError{code: 1}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Output formats.
+const (
+	textOutput = "text"
+	jsonOutput = "json"
+)
+
+// output is the output format selected with the --output flag.
+var output string
+
+func checkOutputFormat() error {
+	if output != textOutput && output != jsonOutput {
+		return fmt.Errorf("invalid output format '%s', must be %s or %s", output, textOutput, jsonOutput)
+	}
+	return nil
+}
+
+// printJSON writes v to the standard output as indented JSON.
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
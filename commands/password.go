@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/lyubenblagoev/emailctl"
+	"github.com/spf13/cobra"
+)
+
+var (
+	passwordMode      string
+	passwordLength    int
+	passwordWords     int
+	passwordSeparator string
+	passwordWordlist  string
+	passwordCount     int
+)
+
+// CreatePasswordCommand creates a password command with all its sub-commands.
+func CreatePasswordCommand() *Command {
+	c := &Command{
+		Command: &cobra.Command{
+			Use:   "password",
+			Short: "Password commands",
+			Long:  "Password is used to access password commands",
+		},
+	}
+
+	generate := BuildCommand(c, generatePassword, "generate [<domain-name> <name>]", "Generate passwords satisfying the password policy", ArgsRangeOption(0, 2), AliasOption("g"))
+	generate.Flags().StringVarP(&passwordMode, "mode", "m", string(emailctl.RandomPasswordMode), "generation mode: random or diceware")
+	generate.Flags().IntVarP(&passwordLength, "length", "l", 0, "number of characters in random mode (derived from the policy by default)")
+	generate.Flags().IntVarP(&passwordWords, "words", "w", 0, "number of words in diceware mode (derived from the policy by default)")
+	generate.Flags().StringVar(&passwordSeparator, "separator", "-", "word separator in diceware mode")
+	generate.Flags().StringVar(&passwordWordlist, "wordlist", "", "word list file for diceware mode (default is the built-in list)")
+	generate.Flags().IntVarP(&passwordCount, "count", "c", 1, "number of passwords to generate")
+
+	BuildCommand(c, checkPassword, "check [<domain-name> <name>]", "Check a password against the password policy and the breached password list", ArgsRangeOption(0, 2))
+
+	return c
+}
+
+func generatePassword(client *emailctl.Client, args []string) error {
+	var domain, username string
+	if len(args) == 2 {
+		domain, username = args[0], args[1]
+	} else if len(args) == 1 {
+		return fmt.Errorf("both domain name and account name are required")
+	}
+
+	generator, err := newPasswordGenerator(client)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < passwordCount; i++ {
+		password, err := generator.Generate(username, domain)
+		if err != nil {
+			return err
+		}
+		fmt.Println(password)
+	}
+
+	return nil
+}
+
+func checkPassword(client *emailctl.Client, args []string) error {
+	var domain, username string
+	if len(args) == 2 {
+		domain, username = args[0], args[1]
+	} else if len(args) == 1 {
+		return fmt.Errorf("both domain name and account name are required")
+	}
+
+	password, err := emailctl.ReadPassword("Password: ")
+	if err != nil {
+		return err
+	}
+	if err := client.Accounts.CheckPassword(domain, username, password); err != nil {
+		return err
+	}
+
+	fmt.Println("Password is acceptable.")
+	return nil
+}
+
+func newPasswordGenerator(client *emailctl.Client) (*emailctl.PasswordGenerator, error) {
+	generator := emailctl.NewPasswordGenerator(client.Accounts.PasswordPolicy())
+	generator.Mode = emailctl.PasswordMode(passwordMode)
+	generator.Length = passwordLength
+	generator.Words = passwordWords
+	generator.Separator = passwordSeparator
+	if passwordWordlist != "" {
+		words, err := emailctl.ReadWordlist(passwordWordlist)
+		if err != nil {
+			return nil, err
+		}
+		generator.Wordlist = words
+	}
+	return generator, nil
+}
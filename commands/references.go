@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/lyubenblagoev/emailctl"
+)
+
+// CreateReferencesCommand creates the references command.
+func CreateReferencesCommand() *Command {
+	c := BuildCommand(nil, listReferences, "references <email>", "List the aliases and BCCs referencing an address", ArgsOption(1), AliasOption("refs"))
+	c.Long = "References lists the aliases in all domains and the sender and recipient BCCs of all accounts " +
+		"which forward or copy mail to the given address."
+	return c
+}
+
+func listReferences(client *emailctl.Client, args []string) error {
+	email := args[0]
+	refs, err := client.FindReferences(email)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		fmt.Printf("No aliases or BCCs reference '%s'.\n", email)
+		return nil
+	}
+
+	fmt.Printf("References to '%s':\n", email)
+	fmt.Printf("%-15s%-40s%-10s\n", "Kind", "Source", "Enabled")
+	for _, ref := range refs {
+		fmt.Printf("%-15s%-40s%-10t\n", ref.Kind, ref.Source(), ref.Enabled)
+	}
+	return nil
+}
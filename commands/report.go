@@ -0,0 +1,344 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/lyubenblagoev/emailctl"
+)
+
+// Report formats.
+const (
+	htmlFormat     = "html"
+	markdownFormat = "markdown"
+)
+
+var (
+	reportFormat string
+	reportFile   string
+)
+
+// CreateReportCommand creates the report command.
+func CreateReportCommand() *Command {
+	c := BuildCommand(nil, writeReport, "report", "Write an HTML or Markdown inventory of all domains", ArgsOption(0))
+	c.Long = "Report writes a self-contained HTML or Markdown document listing all domains with their accounts, " +
+		"BCCs and aliases. Disabled entries and the problems found by lint are highlighted."
+	c.Flags().StringVarP(&reportFormat, "format", "f", markdownFormat, "report format: html or markdown")
+	c.Flags().StringVar(&reportFile, "file", "", "write the report to this file instead of the standard output")
+	return c
+}
+
+func writeReport(client *emailctl.Client, args []string) error {
+	if reportFormat != htmlFormat && reportFormat != markdownFormat {
+		return fmt.Errorf("invalid report format '%s', must be %s or %s", reportFormat, htmlFormat, markdownFormat)
+	}
+
+	state, err := client.LoadState(nil)
+	if err != nil {
+		return err
+	}
+	report := buildReport(state, state.Lint(), time.Now())
+
+	if reportFile == "" {
+		return executeReport(os.Stdout, report)
+	}
+	f, err := os.Create(reportFile)
+	if err != nil {
+		return err
+	}
+	if err := executeReport(f, report); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// executeReport writes the report to out in the format given with --format.
+func executeReport(out io.Writer, r *report) error {
+	w := bufio.NewWriter(out)
+	var err error
+	if reportFormat == htmlFormat {
+		err = htmlReportTemplate.Execute(w, r)
+	} else {
+		err = markdownReportTemplate.Execute(w, r)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+type report struct {
+	Generated string
+	Summary   reportSummary
+	Domains   []*reportDomain
+}
+
+type reportSummary struct {
+	Domains          int
+	DisabledDomains  int
+	Accounts         int
+	DisabledAccounts int
+	Aliases          int
+	DisabledAliases  int
+	Bccs             int
+	DisabledBccs     int
+	Errors           int
+	Warnings         int
+}
+
+type reportDomain struct {
+	Name     string
+	Anchor   string
+	Enabled  bool
+	Accounts []*reportAccount
+	Aliases  []*reportAlias
+	// Findings are the lint findings of the domain which don't belong to an account or
+	// alias row, like alias loops and aliases shadowing accounts.
+	Findings []emailctl.LintFinding
+}
+
+type reportAccount struct {
+	Email        string
+	Enabled      bool
+	SenderBcc    *emailctl.Bcc
+	RecipientBcc *emailctl.Bcc
+	Findings     []emailctl.LintFinding
+}
+
+func (a *reportAccount) Class() string {
+	return reportRowClass(a.Enabled, a.Findings)
+}
+
+type reportAlias struct {
+	Address   string
+	Recipient string
+	Enabled   bool
+	Findings  []emailctl.LintFinding
+}
+
+func (a *reportAlias) Class() string {
+	return reportRowClass(a.Enabled, a.Findings)
+}
+
+// reportRowClass returns the CSS classes highlighting disabled rows and rows with
+// lint findings.
+func reportRowClass(enabled bool, findings []emailctl.LintFinding) string {
+	var classes []string
+	if !enabled {
+		classes = append(classes, "disabled")
+	}
+	severity := ""
+	for _, f := range findings {
+		if severity = f.Severity; severity == emailctl.LintError {
+			break
+		}
+	}
+	if severity != "" {
+		classes = append(classes, severity)
+	}
+	return strings.Join(classes, " ")
+}
+
+// buildReport arranges the state by domain and attaches each lint finding to the
+// account or alias row it was found in, or to its domain.
+func buildReport(state *emailctl.ServerState, findings []emailctl.LintFinding, now time.Time) *report {
+	r := &report{Generated: now.Format("2006-01-02 15:04 MST")}
+	rows := make(map[string]*[]emailctl.LintFinding)
+	domains := make(map[string]*reportDomain)
+
+	for _, d := range state.Domains {
+		rd := &reportDomain{Name: d.Name, Anchor: "domain-" + strings.Replace(strings.ToLower(d.Name), ".", "-", -1), Enabled: d.Enabled}
+		domains[strings.ToLower(d.Name)] = rd
+		r.Domains = append(r.Domains, rd)
+		r.Summary.Domains++
+		if !d.Enabled {
+			r.Summary.DisabledDomains++
+		}
+
+		for _, a := range d.Accounts {
+			ra := &reportAccount{Email: fmt.Sprintf("%s@%s", a.Username, d.Name), Enabled: a.Enabled, SenderBcc: a.SenderBcc, RecipientBcc: a.RecipientBcc}
+			rd.Accounts = append(rd.Accounts, ra)
+			rows["account "+ra.Email] = &ra.Findings
+			r.Summary.Accounts++
+			if !a.Enabled {
+				r.Summary.DisabledAccounts++
+			}
+			bccs := map[string]*emailctl.Bcc{emailctl.SenderBccReference: a.SenderBcc, emailctl.RecipientBccReference: a.RecipientBcc}
+			for kind, bcc := range bccs {
+				if bcc == nil {
+					continue
+				}
+				rows[fmt.Sprintf("%s %s -> %s", kind, ra.Email, bcc.Email)] = &ra.Findings
+				r.Summary.Bccs++
+				if !bcc.Enabled {
+					r.Summary.DisabledBccs++
+				}
+			}
+		}
+
+		for _, a := range d.Aliases {
+			ra := &reportAlias{Address: fmt.Sprintf("%s@%s", a.Name, d.Name), Recipient: a.Email, Enabled: a.Enabled}
+			rd.Aliases = append(rd.Aliases, ra)
+			rows[fmt.Sprintf("alias %s -> %s", ra.Address, ra.Recipient)] = &ra.Findings
+			r.Summary.Aliases++
+			if !a.Enabled {
+				r.Summary.DisabledAliases++
+			}
+		}
+	}
+
+	// Objects are compared case-insensitively, as alias loops are reported in lower case.
+	lower := make(map[string]*[]emailctl.LintFinding)
+	for object, row := range rows {
+		lower[strings.ToLower(object)] = row
+	}
+	for _, f := range findings {
+		if f.Severity == emailctl.LintError {
+			r.Summary.Errors++
+		} else {
+			r.Summary.Warnings++
+		}
+		if row, ok := lower[strings.ToLower(f.Object)]; ok {
+			*row = append(*row, f)
+		} else if rd, ok := domains[strings.ToLower(f.Domain)]; ok {
+			rd.Findings = append(rd.Findings, f)
+		}
+	}
+	return r
+}
+
+var htmlReportTemplate = htmltemplate.Must(htmltemplate.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Email inventory report</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #eee; }
+td.number { text-align: right; }
+.disabled { color: #888; }
+tr.disabled { background: #f4f4f4; }
+tr.warning { background: #fff4d6; }
+tr.error { background: #fde2e2; }
+span.disabled { font-style: italic; }
+li.warning, span.warning { color: #8a5a00; }
+li.error, span.error { color: #b00020; }
+ul.findings { padding-left: 1.2em; margin: 0; }
+</style>
+</head>
+<body>
+<h1>Email inventory report</h1>
+<p>Generated {{.Generated}}.</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th></th><th>Total</th><th>Disabled</th></tr>
+<tr><td>Domains</td><td class="number">{{.Summary.Domains}}</td><td class="number">{{.Summary.DisabledDomains}}</td></tr>
+<tr><td>Accounts</td><td class="number">{{.Summary.Accounts}}</td><td class="number">{{.Summary.DisabledAccounts}}</td></tr>
+<tr><td>Alias recipients</td><td class="number">{{.Summary.Aliases}}</td><td class="number">{{.Summary.DisabledAliases}}</td></tr>
+<tr><td>BCCs</td><td class="number">{{.Summary.Bccs}}</td><td class="number">{{.Summary.DisabledBccs}}</td></tr>
+</table>
+<p>Lint found <span class="error">{{.Summary.Errors}} errors</span> and <span class="warning">{{.Summary.Warnings}} warnings</span>.</p>
+{{- with .Domains}}
+<ul>
+{{- range .}}
+<li><a href="#{{.Anchor}}">{{.Name}}</a>{{if not .Enabled}} <span class="disabled">(disabled)</span>{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{range .Domains}}
+<h2 id="{{.Anchor}}"{{if not .Enabled}} class="disabled"{{end}}>{{.Name}}{{if not .Enabled}} (disabled){{end}}</h2>
+{{- with .Findings}}
+<ul class="findings">
+{{- range .}}
+<li class="{{.Severity}}">{{.Severity}}: {{.Object}}: {{.Message}} [{{.Check}}]</li>
+{{- end}}
+</ul>
+{{- end}}
+
+<h3>Accounts</h3>
+{{- if .Accounts}}
+<table>
+<tr><th>Address</th><th>Status</th><th>Sender BCC</th><th>Recipient BCC</th><th>Findings</th></tr>
+{{- range .Accounts}}
+<tr{{with .Class}} class="{{.}}"{{end}}><td>{{.Email}}</td><td>{{if .Enabled}}enabled{{else}}disabled{{end}}</td>
+<td>{{with .SenderBcc}}{{.Email}}{{if not .Enabled}} <span class="disabled">(disabled)</span>{{end}}{{end}}</td>
+<td>{{with .RecipientBcc}}{{.Email}}{{if not .Enabled}} <span class="disabled">(disabled)</span>{{end}}{{end}}</td>
+<td>{{template "findings" .Findings}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No accounts.</p>
+{{- end}}
+
+<h3>Aliases</h3>
+{{- if .Aliases}}
+<table>
+<tr><th>Alias</th><th>Recipient</th><th>Status</th><th>Findings</th></tr>
+{{- range .Aliases}}
+<tr{{with .Class}} class="{{.}}"{{end}}><td>{{.Address}}</td><td>{{.Recipient}}</td><td>{{if .Enabled}}enabled{{else}}disabled{{end}}</td>
+<td>{{template "findings" .Findings}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No aliases.</p>
+{{- end}}
+{{end}}
+</body>
+</html>
+{{define "findings"}}{{with .}}<ul class="findings">{{range .}}<li class="{{.Severity}}">{{.Severity}}: {{.Message}}</li>{{end}}</ul>{{end}}{{end}}`))
+
+// markdownEscaper escapes the characters with a meaning in Markdown tables and text.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "|", `\|`,
+	"[", `\[`, "]", `\]`, "<", "&lt;", ">", "&gt;", "~", `\~`,
+)
+
+var markdownReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"md": markdownEscaper.Replace,
+}).Parse(`# Email inventory report
+
+Generated {{.Generated}}.
+
+## Summary
+
+| | Total | Disabled |
+|---|---:|---:|
+| Domains | {{.Summary.Domains}} | {{.Summary.DisabledDomains}} |
+| Accounts | {{.Summary.Accounts}} | {{.Summary.DisabledAccounts}} |
+| Alias recipients | {{.Summary.Aliases}} | {{.Summary.DisabledAliases}} |
+| BCCs | {{.Summary.Bccs}} | {{.Summary.DisabledBccs}} |
+
+Lint found **{{.Summary.Errors}} errors** and **{{.Summary.Warnings}} warnings**.
+{{range .Domains}}
+## {{md .Name}}{{if not .Enabled}} (disabled){{end}}
+{{with .Findings}}
+{{range .}}- **{{.Severity}}**: {{md .Object}}: {{md .Message}} \[{{.Check}}\]
+{{end}}{{end}}
+### Accounts
+{{if .Accounts}}
+| Address | Status | Sender BCC | Recipient BCC | Findings |
+|---|---|---|---|---|
+{{range .Accounts}}| {{if .Enabled}}{{md .Email}}{{else}}~~{{md .Email}}~~{{end}} | {{if .Enabled}}enabled{{else}}**disabled**{{end}} | {{template "bcc" .SenderBcc}} | {{template "bcc" .RecipientBcc}} | {{template "findings" .Findings}} |
+{{end}}{{else}}
+No accounts.
+{{end}}
+### Aliases
+{{if .Aliases}}
+| Alias | Recipient | Status | Findings |
+|---|---|---|---|
+{{range .Aliases}}| {{if .Enabled}}{{md .Address}}{{else}}~~{{md .Address}}~~{{end}} | {{md .Recipient}} | {{if .Enabled}}enabled{{else}}**disabled**{{end}} | {{template "findings" .Findings}} |
+{{end}}{{else}}
+No aliases.
+{{end}}{{end}}
+{{- define "bcc"}}{{with .}}{{md .Email}}{{if not .Enabled}} (**disabled**){{end}}{{end}}{{end}}
+{{- define "findings"}}{{range $i, $f := .}}{{if $i}}<br>{{end}}**{{$f.Severity}}**: {{md $f.Message}}{{end}}{{end}}`))
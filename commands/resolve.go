@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lyubenblagoev/emailctl"
+)
+
+// CreateResolveCommand creates the resolve command.
+func CreateResolveCommand() *Command {
+	c := BuildCommand(nil, resolve, "resolve <email> [<sender>]", "Show where mail to an address is delivered", ArgsRangeOption(1, 2))
+	c.Long = "Resolve shows where a message to the given address is delivered by expanding aliases, " +
+		"following recipient BCCs and, if a sender is given, the sender BCC of the sender. " +
+		"Hops which are disabled, or lead to unknown addresses or forwarding loops, are flagged."
+	return c
+}
+
+func resolve(client *emailctl.Client, args []string) error {
+	var sender string
+	if len(args) > 1 {
+		sender = args[1]
+	}
+
+	routes, err := client.Resolve(args[0], sender)
+	if err != nil {
+		return err
+	}
+
+	nodes := make([]*treeNode, len(routes))
+	var deliveries []string
+	seen := make(map[string]bool)
+	for i, r := range routes {
+		nodes[i] = routeNode(r)
+		for _, d := range r.Deliveries() {
+			if !seen[d] {
+				seen[d] = true
+				deliveries = append(deliveries, d)
+			}
+		}
+	}
+	printTree(os.Stdout, nodes)
+
+	if len(deliveries) == 0 {
+		fmt.Printf("\nThe message is not delivered anywhere.\n")
+		return nil
+	}
+	fmt.Printf("\nDelivered to:\n")
+	for _, d := range deliveries {
+		fmt.Printf("  %s\n", d)
+	}
+	return nil
+}
+
+func routeNode(r *emailctl.Route) *treeNode {
+	label := r.Address
+	if r.Via != "" && r.Via != emailctl.AliasRoute {
+		label = fmt.Sprintf("%s: %s", r.Via, label)
+	}
+	if r.Kind != "" {
+		label = fmt.Sprintf("%s [%s]", label, r.Kind)
+	}
+	if r.Problem != "" {
+		label = fmt.Sprintf("%s (%s)", label, r.Problem)
+	}
+	n := &treeNode{label: label}
+	for _, child := range r.Routes {
+		n.children = append(n.children, routeNode(child))
+	}
+	return n
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/lyubenblagoev/emailctl"
+)
+
+var searchOptions struct {
+	regex    bool
+	kinds    []string
+	enabled  bool
+	disabled bool
+}
+
+// CreateSearchCommand creates the search command.
+func CreateSearchCommand() *Command {
+	c := BuildCommand(nil, search, "search <pattern>", "Search domains, accounts, aliases and alias recipients", ArgsOption(1), AliasOption("find"))
+	c.Long = "Search looks for the pattern in the domain names, account usernames, alias names and alias recipients of all domains. " +
+		"The pattern is a case-insensitive glob pattern matching the whole name or address. A pattern without wildcards " +
+		"matches any name or address containing it. With --regex, the pattern is a case-insensitive regular expression."
+	c.Flags().BoolVarP(&searchOptions.regex, "regex", "r", false, "treat the pattern as a regular expression")
+	c.Flags().StringSliceVarP(&searchOptions.kinds, "type", "t", nil, "only search these types: "+strings.Join(emailctl.SearchKinds, ", "))
+	c.Flags().BoolVar(&searchOptions.enabled, "enabled", false, "only show enabled domains, accounts and aliases")
+	c.Flags().BoolVar(&searchOptions.disabled, "disabled", false, "only show disabled domains, accounts and aliases")
+	return c
+}
+
+func search(client *emailctl.Client, args []string) error {
+	match, err := searchMatcher(args[0], searchOptions.regex)
+	if err != nil {
+		return err
+	}
+	opts := &emailctl.SearchOptions{Match: match}
+	for _, k := range searchOptions.kinds {
+		if !isSearchKind(k) {
+			return fmt.Errorf("invalid type '%s', must be one of %s", k, strings.Join(emailctl.SearchKinds, ", "))
+		}
+		opts.Kinds = append(opts.Kinds, k)
+	}
+	if searchOptions.enabled && searchOptions.disabled {
+		return errors.New("--enabled and --disabled can't be used together")
+	}
+	if searchOptions.enabled || searchOptions.disabled {
+		enabled := searchOptions.enabled
+		opts.Enabled = &enabled
+	}
+
+	results, err := client.Search(opts)
+	if err != nil {
+		return err
+	}
+	if output == jsonOutput {
+		if results == nil {
+			results = []emailctl.SearchResult{}
+		}
+		return printJSON(results)
+	}
+	if len(results) == 0 {
+		fmt.Printf("Nothing matches '%s'.\n", args[0])
+		return nil
+	}
+
+	fmt.Printf("%-12s%-40s%-40s%-10s\n", "Type", "Address", "Recipient", "Enabled")
+	for _, r := range results {
+		fmt.Printf("%-12s%-40s%-40s%-10t\n", r.Kind, r.Address, r.Email, r.Enabled)
+	}
+	return nil
+}
+
+// searchMatcher returns a case-insensitive matcher for the search pattern.
+func searchMatcher(pattern string, regex bool) (func(string) bool, error) {
+	if regex {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression '%s': %v", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+
+	pattern = strings.ToLower(pattern)
+	if !strings.ContainsAny(pattern, "*?[") {
+		return func(s string) bool {
+			return strings.Contains(strings.ToLower(s), pattern)
+		}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %v", pattern, err)
+	}
+	return func(s string) bool {
+		matched, _ := path.Match(pattern, strings.ToLower(s))
+		return matched
+	}, nil
+}
+
+func isSearchKind(kind string) bool {
+	for _, k := range emailctl.SearchKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
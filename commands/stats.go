@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/lyubenblagoev/emailctl"
+)
+
+var statsMonths bool
+
+// CreateStatsCommand creates the stats command.
+func CreateStatsCommand() *Command {
+	c := BuildCommand(nil, showStats, "stats [<domain-name>...]", "Show account, alias and BCC statistics")
+	c.Long = "Stats counts the enabled and disabled accounts, the aliases, the distinct alias recipients in local and " +
+		"external domains and the sender and recipient BCCs of each domain, or of the given domains, and in total. " +
+		"It also shows the number of domains, accounts and aliases created per month."
+	c.Flags().BoolVar(&statsMonths, "months", false, "also show the accounts and aliases created per month in each domain")
+	return c
+}
+
+func showStats(client *emailctl.Client, args []string) error {
+	stats, err := client.LoadStats(args)
+	if err != nil {
+		return err
+	}
+	if output == jsonOutput {
+		return printJSON(stats)
+	}
+
+	format := "%-30s%10v%10v%10v%12v%10v%10v%12v%15v\n"
+	fmt.Printf(format, "Domain", "Accounts", "Disabled", "Aliases", "Recipients", "Local", "External", "Sender BCC", "Recipient BCC")
+	row := func(name string, s *emailctl.Stats) {
+		fmt.Printf(format, name, s.Accounts, s.DisabledAccounts, s.Aliases, s.AliasRecipients,
+			s.LocalRecipients, s.ExternalRecipients, s.SenderBccs, s.RecipientBccs)
+	}
+	for _, d := range stats.Domains {
+		row(d.Name+emailctl.DisabledSuffix(d.Enabled), &d.Stats)
+	}
+	row("Total", stats.Total)
+	fmt.Printf("\n%d domains, %d disabled\n", stats.Total.Domains, stats.Total.DisabledDomains)
+
+	fmt.Printf("\nCreated per month:\n")
+	printMonths(stats.Total.Months, true)
+	if statsMonths {
+		for _, d := range stats.Domains {
+			fmt.Printf("\nCreated per month in '%s':\n", d.Name)
+			printMonths(d.Months, false)
+		}
+	}
+	return nil
+}
+
+func printMonths(months []emailctl.MonthStats, domains bool) {
+	if domains {
+		fmt.Printf("%-10s%10s%10s%10s\n", "Month", "Domains", "Accounts", "Aliases")
+	} else {
+		fmt.Printf("%-10s%10s%10s\n", "Month", "Accounts", "Aliases")
+	}
+	for _, m := range months {
+		if domains {
+			fmt.Printf("%-10s%10d%10d%10d\n", m.Month, m.Domains, m.Accounts, m.Aliases)
+		} else {
+			fmt.Printf("%-10s%10d%10d\n", m.Month, m.Accounts, m.Aliases)
+		}
+	}
+}
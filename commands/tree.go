@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+)
+
+// treeNode is a node of a tree printed with printTree.
+type treeNode struct {
+	label    string
+	children []*treeNode
+}
+
+// printTree prints the nodes and their children, one per line, with the children
+// indented below their parent.
+func printTree(w io.Writer, nodes []*treeNode) {
+	for _, n := range nodes {
+		fmt.Fprintln(w, n.label)
+		printChildren(w, n.children, "")
+	}
+}
+
+func printChildren(w io.Writer, nodes []*treeNode, prefix string) {
+	for i, n := range nodes {
+		branch, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, n.label)
+		printChildren(w, n.children, prefix+indent)
+	}
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/lyubenblagoev/emailctl"
+)
+
+// CreateUndoCommand creates the undo command.
+func CreateUndoCommand() *Command {
+	c := BuildCommand(nil, undo, "undo [<id>]", "Undo recent changes", ArgsRangeOption(0, 1))
+	c.Long = "Undo reverts the changes recorded in the audit journal with the given change or session ID. " +
+		"Without an ID, the changes made by the most recent emailctl command are reverted. " +
+		"Deleted accounts are recreated with a new password, which is asked for. Password changes can't be undone."
+	return c
+}
+
+func undo(client *emailctl.Client, args []string) error {
+	var id string
+	if len(args) == 1 {
+		id = args[0]
+	}
+
+	records, err := client.FindUndoable(id)
+	if err != nil {
+		return err
+	}
+
+	details := []string{"The following changes will be reverted:"}
+	for _, r := range records {
+		details = append(details, fmt.Sprintf("  %-14s%-22s%-24s%s", r.ID, r.Time.Local().Format("2006-01-02 15:04:05"), r.Operation, r.Target))
+	}
+	if err := confirm("Undo changes?", details...); err != nil {
+		return err
+	}
+
+	var failed, skipped int
+	for _, result := range client.Undo(records, readUndoPassword) {
+		r := result.Record
+		switch {
+		case result.Err != nil:
+			failed++
+			fmt.Printf("Failed to undo %s %s: %v\n", r.Operation, r.Target, result.Err)
+		case result.Skipped:
+			skipped++
+			fmt.Printf("Can't undo %s %s: %s\n", r.Operation, r.Target, result.Note)
+		case result.Note != "":
+			fmt.Printf("Undone %s %s: %s\n", r.Operation, r.Target, result.Note)
+		default:
+			fmt.Printf("Undone %s %s\n", r.Operation, r.Target)
+		}
+	}
+	if skipped > 0 {
+		fmt.Printf("Undone %d of %d changes, %d can't be undone.\n", len(records)-failed-skipped, len(records), skipped)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to undo %d of %d changes", failed, len(records))
+	}
+	return nil
+}
+
+// readUndoPassword asks for the password of an account recreated by undo.
+func readUndoPassword(email string) (string, error) {
+	fmt.Printf("Account '%s' will be recreated.\n", email)
+	return emailctl.ReadAndConfirmPassword()
+}
@@ -0,0 +1,21 @@
+package emailctl
+
+import (
+	"crypto"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// NewEncryptingWriter returns a WriteCloser that encrypts everything written to it
+// with the passphrase and writes the result to w. The output is a symmetrically
+// encrypted OpenPGP message, so it can be decrypted with 'gpg --decrypt'.
+// The returned writer must be closed to flush the encrypted data.
+func NewEncryptingWriter(w io.Writer, passphrase []byte) (io.WriteCloser, error) {
+	config := &packet.Config{
+		DefaultCipher: packet.CipherAES256,
+		DefaultHash:   crypto.SHA256,
+	}
+	return openpgp.SymmetricallyEncrypt(w, passphrase, nil, config)
+}
@@ -0,0 +1,168 @@
+package emailctl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	defaultConcurrency = 4
+	progressBarWidth   = 30
+)
+
+// Executor runs the items of bulk operations concurrently, with an optional limit on
+// the rate at which items are started, and reports the progress on a terminal.
+type Executor struct {
+	// Concurrency is the maximum number of items processed at the same time.
+	Concurrency int
+	// RateLimit is the maximum number of items started per second. Zero means no limit.
+	RateLimit float64
+	// Progress is where the progress bar is drawn. Progress is only reported if it is a terminal.
+	Progress *os.File
+}
+
+// LoadExecutor creates an Executor using the 'bulk.concurrency' and 'bulk.rateLimit' settings.
+func LoadExecutor() *Executor {
+	e := &Executor{Concurrency: defaultConcurrency}
+	if viper.IsSet("bulk.concurrency") {
+		e.Concurrency = viper.GetInt("bulk.concurrency")
+	}
+	e.RateLimit = viper.GetFloat64("bulk.rateLimit")
+	return e
+}
+
+// ItemError is the error of a single item of a bulk operation.
+type ItemError struct {
+	// Index is the index of the item.
+	Index int
+	// Item is the name of the item.
+	Item string
+	// Err is the error returned for the item.
+	Err error
+}
+
+// BulkError is returned when some of the items of a bulk operation fail.
+type BulkError struct {
+	// Total is the number of items in the operation.
+	Total int
+	// Errors holds the errors of the failed items ordered by index.
+	Errors []ItemError
+}
+
+func (e *BulkError) Error() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%d of %d items failed:", len(e.Errors), e.Total))
+	for _, ie := range e.Errors {
+		buffer.WriteString(fmt.Sprintf("\n  %s: %v", ie.Item, ie.Err))
+	}
+	return buffer.String()
+}
+
+// Run calls fn for the index of each of the items, which are the names used in progress
+// and error reports. All items are processed even if some of them fail; the errors are
+// returned as a *BulkError.
+func (e *Executor) Run(description string, items []string, fn func(i int) error) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	concurrency := e.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var limiter <-chan time.Time
+	if e.RateLimit > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / e.RateLimit))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+	progress := newProgressBar(e.Progress, description, len(items))
+
+	indexes := make(chan int)
+	errs := make([]error, len(items))
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(items); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+				progress.increment()
+			}
+		}()
+	}
+	for i := range items {
+		if limiter != nil {
+			<-limiter
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	progress.finish()
+
+	bulkErr := &BulkError{Total: len(items)}
+	for i, err := range errs {
+		if err != nil {
+			bulkErr.Errors = append(bulkErr.Errors, ItemError{Index: i, Item: items[i], Err: err})
+		}
+	}
+	if len(bulkErr.Errors) > 0 {
+		return bulkErr
+	}
+	return nil
+}
+
+// progressBar draws a progress bar with the estimated remaining time on a terminal.
+type progressBar struct {
+	mu          sync.Mutex
+	out         *os.File
+	description string
+	total       int
+	done        int
+	start       time.Time
+}
+
+func newProgressBar(out *os.File, description string, total int) *progressBar {
+	if out == nil || !terminal.IsTerminal(int(out.Fd())) {
+		out = nil
+	}
+	p := &progressBar{out: out, description: description, total: total, start: time.Now()}
+	p.draw()
+	return p
+}
+
+func (p *progressBar) increment() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.draw()
+}
+
+func (p *progressBar) finish() {
+	if p.out != nil {
+		fmt.Fprint(p.out, "\n")
+	}
+}
+
+func (p *progressBar) draw() {
+	if p.out == nil {
+		return
+	}
+	filled := p.done * progressBarWidth / p.total
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	eta := "--"
+	if p.done > 0 {
+		elapsed := time.Since(p.start)
+		remaining := elapsed / time.Duration(p.done) * time.Duration(p.total-p.done)
+		eta = remaining.Round(time.Second).String()
+	}
+	fmt.Fprintf(p.out, "\r%s [%s] %d/%d ETA %s   ", p.description, bar, p.done, p.total, eta)
+}
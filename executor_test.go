@@ -0,0 +1,149 @@
+package emailctl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecutorRunCollectsErrors(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for _, concurrency := range []int{0, 1, 3, 8, 20} {
+		e := &Executor{Concurrency: concurrency}
+		var mu sync.Mutex
+		called := make(map[int]int)
+		err := e.Run("test", items, func(i int) error {
+			mu.Lock()
+			called[i]++
+			mu.Unlock()
+			if i%3 == 1 {
+				return fmt.Errorf("item %d failed", i)
+			}
+			return nil
+		})
+
+		for i := range items {
+			if called[i] != 1 {
+				t.Errorf("concurrency %d: item %d processed %d times, want 1", concurrency, i, called[i])
+			}
+		}
+		bulkErr, ok := err.(*BulkError)
+		if !ok {
+			t.Errorf("concurrency %d: Run() error = %v, want a *BulkError", concurrency, err)
+			continue
+		}
+		if bulkErr.Total != len(items) {
+			t.Errorf("concurrency %d: Total = %d, want %d", concurrency, bulkErr.Total, len(items))
+		}
+		var failed []string
+		for _, ie := range bulkErr.Errors {
+			if want := fmt.Sprintf("item %d failed", ie.Index); ie.Err.Error() != want || ie.Item != items[ie.Index] {
+				t.Errorf("concurrency %d: error of item %d (%s) = %v, want %s", concurrency, ie.Index, ie.Item, ie.Err, want)
+			}
+			failed = append(failed, ie.Item)
+		}
+		if got := strings.Join(failed, ","); got != "b,e,h" {
+			t.Errorf("concurrency %d: failed items = %s, want b,e,h", concurrency, got)
+		}
+	}
+}
+
+func TestExecutorRunSucceeds(t *testing.T) {
+	e := &Executor{Concurrency: 4}
+	if err := e.Run("test", nil, func(i int) error { return errors.New("called") }); err != nil {
+		t.Errorf("Run() without items error = %v, want nil", err)
+	}
+	if err := e.Run("test", []string{"a", "b"}, func(i int) error { return nil }); err != nil {
+		t.Errorf("Run() error = %v, want nil", err)
+	}
+}
+
+func TestExecutorRunConcurrency(t *testing.T) {
+	e := &Executor{Concurrency: 3}
+	var running, max int32
+	err := e.Run("test", make([]string, 12), func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if max > 3 {
+		t.Errorf("%d items processed at the same time, want at most 3", max)
+	}
+}
+
+func TestExecutorRunRateLimit(t *testing.T) {
+	e := &Executor{Concurrency: 10, RateLimit: 50}
+	start := time.Now()
+	if err := e.Run("test", make([]string, 6), func(i int) error { return nil }); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// Each item waits for a tick, which comes every 20ms.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("6 items at 50 per second took %v, want at least 100ms", elapsed)
+	}
+}
+
+// TestExecutorRunRefreshesTokenOnce runs concurrent requests with an expired
+// authentication token. Run it with -race.
+func TestExecutorRunRefreshesTokenOnce(t *testing.T) {
+	var refreshes int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/auth/refresh-token") {
+			atomic.AddInt32(&refreshes, 1)
+			var body struct {
+				Login        string `json:"login"`
+				RefreshToken string `json:"refreshToken"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Login != "admin" || body.RefreshToken != "refresh" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"token": "new", "refreshToken": "refresh2"})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "name": name, "enabled": true})
+	})
+	c := newTestClient(t, handler, map[string]string{"login": "admin", "authToken": "expired", "refreshToken": "refresh"})
+
+	names := make([]string, 32)
+	for i := range names {
+		names[i] = fmt.Sprintf("domain%d.com", i)
+	}
+	err := c.Bulk.Run("test", names, func(i int) error {
+		d, err := c.Domains.Get(names[i])
+		if err == nil && d.Name != names[i] {
+			err = fmt.Errorf("got domain %s", d.Name)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if refreshes != 1 {
+		t.Errorf("the tokens were refreshed %d times, want 1", refreshes)
+	}
+	if c.GetAuthToken() != "new" || c.GetRefreshToken() != "refresh2" {
+		t.Errorf("tokens = %s, %s, want new, refresh2", c.GetAuthToken(), c.GetRefreshToken())
+	}
+}
@@ -0,0 +1,557 @@
+package emailctl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filterable is implemented by the types which can be selected with a ListFilter.
+type Filterable interface {
+	// FilterValue returns the value of the named field, which is a string, bool, int or
+	// time.Time, or false if the type has no such field.
+	FilterValue(field string) (interface{}, bool)
+}
+
+// FilterValue returns the id, name, enabled, created or updated field of the domain.
+func (d Domain) FilterValue(field string) (interface{}, bool) {
+	switch field {
+	case "id":
+		return d.ID, true
+	case "name":
+		return d.Name, true
+	}
+	return commonFilterValue(field, d.Enabled, d.Created.Time, d.Updated.Time)
+}
+
+// FilterValue returns the id, name (or username), domain, email, enabled, created or
+// updated field of the account. The email is the address of the account.
+func (a Account) FilterValue(field string) (interface{}, bool) {
+	switch field {
+	case "id":
+		return a.ID, true
+	case "name", "username":
+		return a.Username, true
+	case "domain":
+		return a.Domain, true
+	case "email":
+		return fmt.Sprintf("%s@%s", a.Username, a.Domain), true
+	}
+	return commonFilterValue(field, a.Enabled, a.Created.Time, a.Updated.Time)
+}
+
+// FilterValue returns the id, name, email, enabled, created or updated field of the
+// alias. The email is the recipient of the alias.
+func (a Alias) FilterValue(field string) (interface{}, bool) {
+	switch field {
+	case "id":
+		return a.ID, true
+	case "name":
+		return a.Name, true
+	case "email":
+		return a.Email, true
+	}
+	return commonFilterValue(field, a.Enabled, a.Created.Time, a.Updated.Time)
+}
+
+func commonFilterValue(field string, enabled bool, created, updated time.Time) (interface{}, bool) {
+	switch field {
+	case "enabled":
+		return enabled, true
+	case "created":
+		return created, true
+	case "updated":
+		return updated, true
+	}
+	return nil, false
+}
+
+// ListFilter selects domains, accounts and aliases. All of the set conditions must hold.
+type ListFilter struct {
+	// Enabled selects enabled or disabled objects, if not nil.
+	Enabled *bool
+	// CreatedBefore and CreatedAfter select objects created before or after the time, if not zero.
+	CreatedBefore time.Time
+	CreatedAfter  time.Time
+	// UpdatedSince selects objects updated at or after the time, if not zero.
+	UpdatedSince time.Time
+	// NameMatch selects objects whose name matches the regular expression, if not nil.
+	NameMatch *regexp.Regexp
+	// Expression selects objects for which the filter expression is true, if not nil.
+	Expression *FilterExpression
+}
+
+// Match reports whether v is selected by the filter. An error is returned if the
+// filter expression refers to a field v doesn't have or compares a field to a value
+// of the wrong type.
+func (f *ListFilter) Match(v Filterable) (bool, error) {
+	if f.Enabled != nil {
+		value, ok := v.FilterValue("enabled")
+		enabled, isBool := value.(bool)
+		if !ok || !isBool {
+			return false, fmt.Errorf("the enabled filter can't be applied")
+		}
+		if enabled != *f.Enabled {
+			return false, nil
+		}
+	}
+	if !f.CreatedBefore.IsZero() || !f.CreatedAfter.IsZero() {
+		created, err := filterTime(v, "created")
+		if err != nil {
+			return false, err
+		}
+		if !f.CreatedBefore.IsZero() && !created.Before(f.CreatedBefore) {
+			return false, nil
+		}
+		if !f.CreatedAfter.IsZero() && !created.After(f.CreatedAfter) {
+			return false, nil
+		}
+	}
+	if !f.UpdatedSince.IsZero() {
+		updated, err := filterTime(v, "updated")
+		if err != nil {
+			return false, err
+		}
+		if updated.Before(f.UpdatedSince) {
+			return false, nil
+		}
+	}
+	if f.NameMatch != nil {
+		value, ok := v.FilterValue("name")
+		name, isString := value.(string)
+		if !ok || !isString {
+			return false, fmt.Errorf("the name filter can't be applied")
+		}
+		if !f.NameMatch.MatchString(name) {
+			return false, nil
+		}
+	}
+	if f.Expression != nil {
+		return f.Expression.Match(v)
+	}
+	return true, nil
+}
+
+// filterTime returns the value of a time field of v.
+func filterTime(v Filterable, field string) (time.Time, error) {
+	value, ok := v.FilterValue(field)
+	t, isTime := value.(time.Time)
+	if !ok || !isTime {
+		return time.Time{}, fmt.Errorf("the %s filter can't be applied", field)
+	}
+	return t, nil
+}
+
+// Domains returns the domains selected by the filter.
+func (f *ListFilter) Domains(domains []Domain) ([]Domain, error) {
+	var selected []Domain
+	for _, d := range domains {
+		ok, err := f.Match(d)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			selected = append(selected, d)
+		}
+	}
+	return selected, nil
+}
+
+// Accounts returns the accounts selected by the filter.
+func (f *ListFilter) Accounts(accounts []Account) ([]Account, error) {
+	var selected []Account
+	for _, a := range accounts {
+		ok, err := f.Match(a)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			selected = append(selected, a)
+		}
+	}
+	return selected, nil
+}
+
+// Aliases returns the aliases selected by the filter.
+func (f *ListFilter) Aliases(aliases []Alias) ([]Alias, error) {
+	var selected []Alias
+	for _, a := range aliases {
+		ok, err := f.Match(a)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			selected = append(selected, a)
+		}
+	}
+	return selected, nil
+}
+
+// FilterExpression is a parsed filter expression such as
+// 'enabled == false && created < 2024-01-01'.
+//
+// Comparisons have a field name on the left, one of the operators ==, !=, <, <=, >,
+// >=, =~ (matches regular expression) and !~ (doesn't match) and a value on the right.
+// Values containing spaces or operator characters are quoted with single or double
+// quotes. Strings are compared case-insensitively. Times are given as dates, RFC 3339
+// times or as an age such as 7d or 24h; a date compared with == or != matches the
+// whole day. A boolean field on its own is true if the field is true. Comparisons are
+// combined with !, && and || and grouped with parentheses.
+type FilterExpression struct {
+	source string
+	expr   filterNode
+}
+
+// ParseFilter parses a filter expression.
+func ParseFilter(s string) (*FilterExpression, error) {
+	tokens, err := tokenizeFilter(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected '%s'", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+	return &FilterExpression{source: s, expr: expr}, nil
+}
+
+func (e *FilterExpression) String() string {
+	return e.source
+}
+
+// Match evaluates the expression for v.
+func (e *FilterExpression) Match(v Filterable) (bool, error) {
+	ok, err := e.expr.eval(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid filter: %v", err)
+	}
+	return ok, nil
+}
+
+// Filter token kinds.
+const (
+	wordToken = iota
+	quotedToken
+	operatorToken
+)
+
+type filterToken struct {
+	kind int
+	text string
+}
+
+var filterOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+func tokenizeFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			tokens = append(tokens, filterToken{kind: quotedToken, text: s[i+1 : i+1+end]})
+			i += end + 2
+		default:
+			if op := filterOperatorAt(s[i:]); op != "" {
+				tokens = append(tokens, filterToken{kind: operatorToken, text: op})
+				i += len(op)
+				continue
+			}
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n'\"", rune(s[i])) && filterOperatorAt(s[i:]) == "" {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: wordToken, text: s[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+func filterOperatorAt(s string) string {
+	for _, op := range filterOperators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+// accept consumes the next token if it is the given operator.
+func (p *filterParser) accept(op string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == operatorToken && p.tokens[p.pos].text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.accept("!") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{expr}, nil
+	}
+	if p.accept("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	field := p.tokens[p.pos]
+	if field.kind != wordToken {
+		return nil, fmt.Errorf("expected a field name, got '%s'", field.text)
+	}
+	p.pos++
+
+	var op string
+	for _, o := range []string{"==", "!=", "<=", ">=", "<", ">", "=~", "!~"} {
+		if p.accept(o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return &fieldNode{field.text}, nil
+	}
+
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind == operatorToken {
+		return nil, fmt.Errorf("missing value after '%s %s'", field.text, op)
+	}
+	c := &compareNode{field: field.text, op: op, value: p.tokens[p.pos].text}
+	p.pos++
+	if op == "=~" || op == "!~" {
+		re, err := regexp.Compile(c.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression '%s': %v", c.value, err)
+		}
+		c.re = re
+	}
+	return c, nil
+}
+
+type filterNode interface {
+	eval(v Filterable) (bool, error)
+}
+
+type orNode struct {
+	left, right filterNode
+}
+
+func (n *orNode) eval(v Filterable) (bool, error) {
+	ok, err := n.left.eval(v)
+	if err != nil || ok {
+		return ok, err
+	}
+	return n.right.eval(v)
+}
+
+type andNode struct {
+	left, right filterNode
+}
+
+func (n *andNode) eval(v Filterable) (bool, error) {
+	ok, err := n.left.eval(v)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return n.right.eval(v)
+}
+
+type notNode struct {
+	expr filterNode
+}
+
+func (n *notNode) eval(v Filterable) (bool, error) {
+	ok, err := n.expr.eval(v)
+	return !ok, err
+}
+
+// fieldNode is a boolean field on its own.
+type fieldNode struct {
+	field string
+}
+
+func (n *fieldNode) eval(v Filterable) (bool, error) {
+	value, ok := v.FilterValue(n.field)
+	if !ok {
+		return false, fmt.Errorf("unknown field '%s'", n.field)
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("field '%s' is not a boolean, compare it to a value", n.field)
+	}
+	return b, nil
+}
+
+type compareNode struct {
+	field string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (n *compareNode) eval(v Filterable) (bool, error) {
+	value, ok := v.FilterValue(n.field)
+	if !ok {
+		return false, fmt.Errorf("unknown field '%s'", n.field)
+	}
+	if n.re != nil {
+		s, ok := value.(string)
+		if !ok {
+			return false, fmt.Errorf("'%s' can only be used with text fields", n.op)
+		}
+		return n.re.MatchString(s) == (n.op == "=~"), nil
+	}
+
+	switch value := value.(type) {
+	case string:
+		return compareResult(n.op, strings.Compare(strings.ToLower(value), strings.ToLower(n.value))), nil
+	case bool:
+		b, err := strconv.ParseBool(n.value)
+		if err != nil {
+			return false, fmt.Errorf("field '%s' must be compared to true or false", n.field)
+		}
+		if n.op != "==" && n.op != "!=" {
+			return false, fmt.Errorf("field '%s' can only be compared with == and !=", n.field)
+		}
+		return (value == b) == (n.op == "=="), nil
+	case int:
+		i, err := strconv.Atoi(n.value)
+		if err != nil {
+			return false, fmt.Errorf("field '%s' must be compared to a number", n.field)
+		}
+		return compareResult(n.op, compareInts(value, i)), nil
+	case time.Time:
+		t, day, err := ParseTime(n.value)
+		if err != nil {
+			return false, fmt.Errorf("field '%s': %v", n.field, err)
+		}
+		if day && (n.op == "==" || n.op == "!=") {
+			y1, m1, d1 := value.In(time.Local).Date()
+			y2, m2, d2 := t.Date()
+			return (y1 == y2 && m1 == m2 && d1 == d2) == (n.op == "=="), nil
+		}
+		return compareResult(n.op, compareTimes(value, t)), nil
+	}
+	return false, fmt.Errorf("field '%s' can't be compared", n.field)
+}
+
+// compareResult applies the comparison operator to the result of a three-way comparison.
+func compareResult(op string, cmp int) bool {
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// ParseTime parses a date ("2006-01-02"), a date and time ("2006-01-02 15:04"), an
+// RFC 3339 time or an age relative to now ("36h", "7d"). Dates and times without a zone
+// are in the local time zone. day reports whether a date without a time was given.
+func ParseTime(s string) (t time.Time, day bool, err error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, false, nil
+		}
+	}
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil {
+			return time.Now().AddDate(0, 0, -days), false, nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid time '%s'", s)
+}
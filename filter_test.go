@@ -0,0 +1,244 @@
+package emailctl
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/lyubenblagoev/goprsc"
+)
+
+func TestTokenizeFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		tokens []filterToken
+		err    bool
+	}{
+		{"", nil, false},
+		{"enabled", []filterToken{{wordToken, "enabled"}}, false},
+		{"name=~'^a b'", []filterToken{{wordToken, "name"}, {operatorToken, "=~"}, {quotedToken, "^a b"}}, false},
+		{`email == "x@y.com"`, []filterToken{{wordToken, "email"}, {operatorToken, "=="}, {quotedToken, "x@y.com"}}, false},
+		{"name !~ x", []filterToken{{wordToken, "name"}, {operatorToken, "!~"}, {wordToken, "x"}}, false},
+		{"!enabled", []filterToken{{operatorToken, "!"}, {wordToken, "enabled"}}, false},
+		{"id<=3&&!(a||b)", []filterToken{
+			{wordToken, "id"}, {operatorToken, "<="}, {wordToken, "3"}, {operatorToken, "&&"}, {operatorToken, "!"},
+			{operatorToken, "("}, {wordToken, "a"}, {operatorToken, "||"}, {wordToken, "b"}, {operatorToken, ")"},
+		}, false},
+		{"name == 'a && b'", []filterToken{{wordToken, "name"}, {operatorToken, "=="}, {quotedToken, "a && b"}}, false},
+		{"name == ''", []filterToken{{wordToken, "name"}, {operatorToken, "=="}, {quotedToken, ""}}, false},
+		{"name == 'abc", nil, true},
+	}
+	for _, tt := range tests {
+		tokens, err := tokenizeFilter(tt.filter)
+		if (err != nil) != tt.err {
+			t.Errorf("tokenizeFilter(%q) error = %v, want error %t", tt.filter, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, tt.tokens) {
+			t.Errorf("tokenizeFilter(%q) = %v, want %v", tt.filter, tokens, tt.tokens)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, filter := range []string{
+		"",
+		"name ==",
+		"name == && enabled",
+		"(enabled",
+		"enabled)",
+		"enabled enabled",
+		"== x",
+		"name =~ '('",
+		"name == 'x",
+		"enabled &&",
+	} {
+		if _, err := ParseFilter(filter); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded, want an error", filter)
+		}
+	}
+}
+
+func testAccount(username string, enabled bool, created time.Time) Account {
+	return Account{&goprsc.Account{
+		ID:       7,
+		Username: username,
+		Domain:   "example.com",
+		Enabled:  enabled,
+		Created:  goprsc.DateTime{Time: created},
+		Updated:  goprsc.DateTime{Time: created},
+	}}
+}
+
+func TestFilterExpressionMatch(t *testing.T) {
+	created := time.Date(2024, 3, 15, 13, 30, 0, 0, time.Local)
+	john := testAccount("john", true, created)
+	mary := testAccount("mary", false, created.AddDate(0, 0, 1))
+
+	tests := []struct {
+		filter string
+		john   bool
+		mary   bool
+	}{
+		// Precedence: ! binds tighter than &&, which binds tighter than ||.
+		{"enabled", true, false},
+		{"!enabled", false, true},
+		{"name == mary || name == john && !enabled", false, true},
+		{"(name == mary || name == john) && !enabled", false, true},
+		{"name == john || name == mary && enabled", true, false},
+		{"(name == john || name == mary) && enabled", true, false},
+		{"!(name == john) && !enabled", false, true},
+		{"!!enabled", true, false},
+		// Strings are compared case-insensitively.
+		{"name == JOHN", true, false},
+		{"email == 'John@Example.com'", true, false},
+		{"name != john", false, true},
+		// Regular expressions, and !~ as an operator rather than ! followed by ~.
+		{"name =~ '^j'", true, false},
+		{"name !~ '^j'", false, true},
+		{"name!~^j", false, true},
+		{"!(name =~ '^j')", false, true},
+		// Quoted values may contain spaces and operator characters.
+		{`name =~ "^(john|mary)$"`, true, true},
+		{"name == 'john && enabled'", false, false},
+		// Numbers.
+		{"id == 7", true, true},
+		{"id > 7", false, false},
+		{"id <= 7", true, true},
+		// A date compared with == or != matches the whole day.
+		{"created == 2024-03-15", true, false},
+		{"created != 2024-03-15", false, true},
+		{"created == 2024-03-16", false, true},
+		{"created < 2024-03-16", true, false},
+		{"created >= 2024-03-15", true, true},
+		{"created > 2024-03-15", true, true},
+		{"created == '2024-03-15 13:30'", true, false},
+		{"created < '2024-03-15 13:30'", false, false},
+		{"created > 1d", false, false},
+		{"created < 1h", true, true},
+	}
+	for _, tt := range tests {
+		e, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.filter, err)
+			continue
+		}
+		for _, c := range []struct {
+			account Account
+			want    bool
+		}{{john, tt.john}, {mary, tt.mary}} {
+			ok, err := e.Match(c.account)
+			if err != nil {
+				t.Errorf("%q.Match(%s): %v", tt.filter, c.account.Username, err)
+				continue
+			}
+			if ok != c.want {
+				t.Errorf("%q.Match(%s) = %t, want %t", tt.filter, c.account.Username, ok, c.want)
+			}
+		}
+	}
+}
+
+func TestFilterExpressionMatchErrors(t *testing.T) {
+	john := testAccount("john", true, time.Now())
+	for _, filter := range []string{
+		"unknown == x",
+		"unknown",
+		"name",
+		"enabled == yes",
+		"enabled < true",
+		"id == seven",
+		"created < yesterday",
+		"name < john || unknown",
+		"enabled && unknown",
+	} {
+		e, err := ParseFilter(filter)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", filter, err)
+			continue
+		}
+		if _, err := e.Match(john); err == nil {
+			t.Errorf("%q.Match succeeded, want an error", filter)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+		day   bool
+	}{
+		{"2024-03-15", time.Date(2024, 3, 15, 0, 0, 0, 0, time.Local), true},
+		{"2024-03-15 13:30", time.Date(2024, 3, 15, 13, 30, 0, 0, time.Local), false},
+		{"2024-03-15 13:30:05", time.Date(2024, 3, 15, 13, 30, 5, 0, time.Local), false},
+		{"2024-03-15T13:30:05", time.Date(2024, 3, 15, 13, 30, 5, 0, time.Local), false},
+		{"2024-03-15T13:30:05Z", time.Date(2024, 3, 15, 13, 30, 5, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		got, day, err := ParseTime(tt.value)
+		if err != nil || !got.Equal(tt.want) || day != tt.day {
+			t.Errorf("ParseTime(%q) = %v, %t, %v, want %v, %t", tt.value, got, day, err, tt.want, tt.day)
+		}
+	}
+
+	for _, tt := range []struct {
+		value string
+		age   time.Duration
+	}{
+		{"7d", 7 * 24 * time.Hour},
+		{"36h", 36 * time.Hour},
+		{"90m", 90 * time.Minute},
+	} {
+		got, day, err := ParseTime(tt.value)
+		if err != nil || day {
+			t.Errorf("ParseTime(%q) = %v, %t, %v", tt.value, got, day, err)
+			continue
+		}
+		// Days are calendar days, which may differ from 24 hours across a DST change.
+		if age := time.Since(got); age < tt.age-2*time.Hour || age > tt.age+2*time.Hour {
+			t.Errorf("ParseTime(%q) is %v ago, want about %v", tt.value, age, tt.age)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "2024-13-01", "7 days", "d"} {
+		if _, _, err := ParseTime(value); err == nil {
+			t.Errorf("ParseTime(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestListFilterMatch(t *testing.T) {
+	created := time.Date(2024, 3, 15, 13, 30, 0, 0, time.Local)
+	john := testAccount("john", true, created)
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name   string
+		filter ListFilter
+		want   bool
+	}{
+		{"empty", ListFilter{}, true},
+		{"enabled", ListFilter{Enabled: &enabled}, true},
+		{"disabled", ListFilter{Enabled: &disabled}, false},
+		{"created before", ListFilter{CreatedBefore: created.Add(time.Minute)}, true},
+		{"created before, same time", ListFilter{CreatedBefore: created}, false},
+		{"created after", ListFilter{CreatedAfter: created.Add(-time.Minute)}, true},
+		{"created after, later", ListFilter{CreatedAfter: created.Add(time.Minute)}, false},
+		{"updated since, same time", ListFilter{UpdatedSince: created}, true},
+		{"updated since, later", ListFilter{UpdatedSince: created.Add(time.Minute)}, false},
+		{"name", ListFilter{NameMatch: regexp.MustCompile("^jo")}, true},
+		{"name mismatch", ListFilter{NameMatch: regexp.MustCompile("^ma")}, false},
+	}
+	for _, tt := range tests {
+		ok, err := tt.filter.Match(john)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if ok != tt.want {
+			t.Errorf("%s: Match = %t, want %t", tt.name, ok, tt.want)
+		}
+	}
+}
@@ -3,8 +3,9 @@ module github.com/lyubenblagoev/emailctl
 go 1.15
 
 require (
+	github.com/ProtonMail/go-crypto v1.0.0
 	github.com/lyubenblagoev/goprsc v0.2.0
 	github.com/spf13/cobra v0.0.3
 	github.com/spf13/viper v1.2.1
-	golang.org/x/crypto v0.0.0-20181029021203-45a5f77698d3
+	golang.org/x/crypto v0.7.0
 )
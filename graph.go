@@ -0,0 +1,131 @@
+package emailctl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Graph node kinds.
+const (
+	DomainNode   = "domain"
+	AccountNode  = "account"
+	AliasNode    = "alias"
+	ExternalNode = "external"
+	// UnknownNode is an address in a local domain without an account or alias.
+	UnknownNode = "unknown"
+)
+
+// MemberEdge is the kind of the edges from domains to their accounts and aliases. The
+// other edges are of the reference kinds AliasReference, SenderBccReference and
+// RecipientBccReference.
+const MemberEdge = "member"
+
+// GraphNode is a domain or an email address in the forwarding graph.
+type GraphNode struct {
+	// ID is the domain name or the lower-case email address.
+	ID string `json:"id"`
+	// Kind is one of the graph node kinds.
+	Kind string `json:"kind"`
+	// Label is the domain name or email address as shown.
+	Label string `json:"label"`
+	// Domain is the local domain of the node, or empty for external addresses.
+	Domain string `json:"domain,omitempty"`
+	// Enabled reports whether the node, and its domain, are enabled.
+	Enabled bool `json:"enabled"`
+}
+
+// GraphEdge is a relation between two nodes of the forwarding graph.
+type GraphEdge struct {
+	// From and To are the IDs of the nodes.
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Kind is MemberEdge or one of the reference kinds.
+	Kind string `json:"kind"`
+	// Enabled reports whether the alias or BCC is enabled.
+	Enabled bool `json:"enabled"`
+}
+
+// ForwardingGraph is the graph of the domains, accounts and aliases on the server and
+// of the aliases and BCCs forwarding or copying mail between them.
+type ForwardingGraph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []GraphEdge  `json:"edges"`
+}
+
+// Graph builds the forwarding graph of the state. The state must include the BCCs for
+// the BCC edges to be included.
+func (s *ServerState) Graph() *ForwardingGraph {
+	g := &ForwardingGraph{}
+	nodes := make(map[string]*GraphNode)
+	addNode := func(n *GraphNode) *GraphNode {
+		if existing, ok := nodes[n.ID]; ok {
+			return existing
+		}
+		nodes[n.ID] = n
+		g.Nodes = append(g.Nodes, n)
+		return n
+	}
+
+	for _, d := range s.Domains {
+		addNode(&GraphNode{ID: strings.ToLower(d.Name), Kind: DomainNode, Label: d.Name, Domain: d.Name, Enabled: d.Enabled})
+		for _, a := range d.Accounts {
+			email := fmt.Sprintf("%s@%s", a.Username, d.Name)
+			addNode(&GraphNode{ID: strings.ToLower(email), Kind: AccountNode, Label: email, Domain: d.Name, Enabled: d.Enabled && a.Enabled})
+			g.Edges = append(g.Edges, GraphEdge{From: strings.ToLower(d.Name), To: strings.ToLower(email), Kind: MemberEdge, Enabled: true})
+		}
+	}
+	for _, d := range s.Domains {
+		for _, a := range d.Aliases {
+			email := fmt.Sprintf("%s@%s", a.Name, d.Name)
+			id := strings.ToLower(email)
+			if _, ok := nodes[id]; !ok {
+				addNode(&GraphNode{ID: id, Kind: AliasNode, Label: email, Domain: d.Name})
+				g.Edges = append(g.Edges, GraphEdge{From: strings.ToLower(d.Name), To: id, Kind: MemberEdge, Enabled: true})
+			}
+			if n := nodes[id]; n.Kind == AliasNode && a.Enabled {
+				n.Enabled = d.Enabled
+			}
+		}
+	}
+
+	for _, ref := range s.References(func(string) bool { return true }) {
+		to := strings.ToLower(ref.Email)
+		if _, ok := nodes[to]; !ok {
+			n := &GraphNode{ID: to, Kind: ExternalNode, Label: ref.Email, Enabled: true}
+			if i := strings.LastIndex(ref.Email, "@"); i >= 0 {
+				if d := s.FindDomain(ref.Email[i+1:]); d != nil {
+					n.Kind, n.Domain, n.Enabled = UnknownNode, d.Name, false
+				}
+			}
+			addNode(n)
+		}
+		g.Edges = append(g.Edges, GraphEdge{From: strings.ToLower(ref.Source()), To: to, Kind: ref.Kind, Enabled: ref.Enabled})
+	}
+	return g
+}
+
+// FilterDomain returns the part of the graph with the nodes of the domain and the
+// nodes connected to them by aliases or BCCs.
+func (g *ForwardingGraph) FilterDomain(domain string) *ForwardingGraph {
+	inDomain := make(map[string]bool)
+	for _, n := range g.Nodes {
+		if strings.EqualFold(n.Domain, domain) {
+			inDomain[n.ID] = true
+		}
+	}
+
+	filtered := &ForwardingGraph{}
+	keep := make(map[string]bool)
+	for _, e := range g.Edges {
+		if inDomain[e.From] || inDomain[e.To] && e.Kind != MemberEdge {
+			filtered.Edges = append(filtered.Edges, e)
+			keep[e.From], keep[e.To] = true, true
+		}
+	}
+	for _, n := range g.Nodes {
+		if keep[n.ID] || inDomain[n.ID] {
+			filtered.Nodes = append(filtered.Nodes, n)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,259 @@
+package emailctl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Lint severities.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// Lint checks.
+const (
+	DanglingAliasCheck      = "dangling-alias"
+	DisabledTargetCheck     = "disabled-target"
+	ShadowingAliasCheck     = "shadowing-alias"
+	AliasLoopCheck          = "alias-loop"
+	SelfBccCheck            = "self-bcc"
+	DisabledBccCheck        = "disabled-bcc-target"
+	DisabledDomainCheck     = "account-in-disabled-domain"
+	DuplicateRecipientCheck = "duplicate-recipient"
+)
+
+// LintFinding is a problem found in the server configuration.
+type LintFinding struct {
+	// Check is the name of the check which found the problem.
+	Check string `json:"check"`
+	// Domain is the domain of the object with the problem.
+	Domain string `json:"domain"`
+	// Severity is LintError for problems which lose or misroute mail and LintWarning otherwise.
+	Severity string `json:"severity"`
+	// Object is the domain, account, alias or BCC with the problem.
+	Object string `json:"object"`
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+func (f *LintFinding) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", f.Severity, f.Object, f.Message, f.Check)
+}
+
+// Lint checks the server state for aliases pointing to unknown or disabled local
+// addresses, aliases shadowing accounts, alias loops, BCCs to the account itself or to
+// disabled accounts, enabled accounts in disabled domains and recipients of the same
+// alias differing only in case. The state must include the BCCs.
+func (s *ServerState) Lint() []LintFinding {
+	l := &linter{state: s}
+	for _, d := range s.Domains {
+		l.lintDomain(d)
+	}
+	l.lintLoops()
+	return l.findings
+}
+
+type linter struct {
+	state    *ServerState
+	findings []LintFinding
+}
+
+func (l *linter) add(check, severity, domain, object, format string, args ...interface{}) {
+	l.findings = append(l.findings, LintFinding{
+		Check:    check,
+		Domain:   domain,
+		Severity: severity,
+		Object:   object,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) lintDomain(d *DomainState) {
+	for _, a := range d.Accounts {
+		email := fmt.Sprintf("%s@%s", a.Username, d.Name)
+		if !d.Enabled && a.Enabled {
+			l.add(DisabledDomainCheck, LintWarning, d.Name, "account "+email,
+				"account is enabled, but domain '%s' is disabled", d.Name)
+		}
+		if a.SenderBcc != nil {
+			l.lintBcc(SenderBccReference, d.Name, email, a.SenderBcc)
+		}
+		if a.RecipientBcc != nil {
+			l.lintBcc(RecipientBccReference, d.Name, email, a.RecipientBcc)
+		}
+	}
+
+	recipients := make(map[string][]Alias)
+	var names []string
+	for _, a := range d.Aliases {
+		key := strings.ToLower(a.Name)
+		if _, ok := recipients[key]; !ok {
+			names = append(names, key)
+		}
+		recipients[key] = append(recipients[key], a)
+	}
+	for _, name := range names {
+		l.lintAlias(d, recipients[name])
+	}
+}
+
+func (l *linter) lintBcc(kind, domain, email string, bcc *Bcc) {
+	object := fmt.Sprintf("%s %s -> %s", kind, email, bcc.Email)
+	if strings.EqualFold(bcc.Email, email) {
+		l.add(SelfBccCheck, LintWarning, domain, object, "the BCC copies mail to the account itself")
+		return
+	}
+	if !bcc.Enabled {
+		return
+	}
+	if problem := l.targetProblem(bcc.Email); problem != "" {
+		l.add(DisabledBccCheck, LintError, domain, object, "the BCC target %s", problem)
+	}
+}
+
+// lintAlias checks the recipients of the alias with the given name.
+func (l *linter) lintAlias(d *DomainState, aliases []Alias) {
+	name := aliases[0].Name
+	email := fmt.Sprintf("%s@%s", name, d.Name)
+
+	_, account := l.state.FindAccount(email)
+	if account != nil {
+		self := false
+		for _, a := range aliases {
+			self = self || strings.EqualFold(a.Email, email)
+		}
+		if !self {
+			l.add(ShadowingAliasCheck, LintError, d.Name, "alias "+email,
+				"the alias shadows account '%s', which receives no mail", email)
+		}
+	}
+
+	seen := make(map[string]string)
+	for _, a := range aliases {
+		object := fmt.Sprintf("alias %s -> %s", email, a.Email)
+		key := strings.ToLower(a.Email)
+		if other, ok := seen[key]; ok && other != a.Email {
+			l.add(DuplicateRecipientCheck, LintWarning, d.Name, object,
+				"recipient differs only in case from '%s'", other)
+		}
+		seen[key] = a.Email
+
+		if !a.Enabled || strings.EqualFold(a.Email, email) {
+			continue
+		}
+		if problem := l.targetProblem(a.Email); problem != "" {
+			check := DisabledTargetCheck
+			if strings.HasPrefix(problem, "does not exist") {
+				check = DanglingAliasCheck
+			}
+			l.add(check, LintError, d.Name, object, "the recipient %s", problem)
+		}
+	}
+}
+
+// targetProblem returns why mail to the local address is not delivered, or an empty
+// string if it is delivered or the address isn't local.
+func (l *linter) targetProblem(email string) string {
+	d, account := l.state.FindAccount(email)
+	if d == nil {
+		return ""
+	}
+	if !d.Enabled {
+		return fmt.Sprintf("is in disabled domain '%s'", d.Name)
+	}
+	if account != nil {
+		if !account.Enabled {
+			return "is a disabled account"
+		}
+		return ""
+	}
+	for _, a := range d.Aliases {
+		if strings.EqualFold(fmt.Sprintf("%s@%s", a.Name, d.Name), email) {
+			return ""
+		}
+	}
+	return "does not exist"
+}
+
+// lintLoops reports each cycle in the graph of enabled aliases once.
+func (l *linter) lintLoops() {
+	graph := make(map[string][]string)
+	domains := make(map[string]string)
+	var nodes []string
+	for _, d := range l.state.Domains {
+		for _, a := range d.Aliases {
+			if !a.Enabled {
+				continue
+			}
+			from := strings.ToLower(fmt.Sprintf("%s@%s", a.Name, d.Name))
+			to := strings.ToLower(a.Email)
+			if from == to {
+				continue
+			}
+			if _, ok := graph[from]; !ok {
+				nodes = append(nodes, from)
+				domains[from] = d.Name
+			}
+			graph[from] = append(graph[from], to)
+		}
+	}
+
+	reported := make(map[string]bool)
+	for _, cycle := range findCycles(graph, nodes) {
+		key := cycleKey(cycle)
+		if reported[key] {
+			continue
+		}
+		reported[key] = true
+		l.add(AliasLoopCheck, LintError, domains[cycle[0]], "alias "+cycle[0],
+			"forwarding loop: %s -> %s", strings.Join(cycle, " -> "), cycle[0])
+	}
+}
+
+// findCycles returns the cycles found by a depth-first search of the graph, starting
+// from each of the nodes in order.
+func findCycles(graph map[string][]string, nodes []string) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var path []string
+	var cycles [][]string
+	var visit func(n string)
+	visit = func(n string) {
+		state[n] = visiting
+		path = append(path, n)
+		for _, next := range graph[n] {
+			switch state[next] {
+			case visiting:
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == next {
+						cycles = append(cycles, append([]string(nil), path[i:]...))
+						break
+					}
+				}
+			case unvisited:
+				visit(next)
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = visited
+	}
+	for _, n := range nodes {
+		if state[n] == unvisited {
+			visit(n)
+		}
+	}
+	return cycles
+}
+
+// cycleKey returns the same key for all rotations of a cycle.
+func cycleKey(cycle []string) string {
+	sorted := append([]string(nil), cycle...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
@@ -0,0 +1,49 @@
+package emailctl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		graph  map[string][]string
+		nodes  []string
+		cycles [][]string
+	}{
+		{"empty", nil, nil, nil},
+		{"chain", map[string][]string{"a": {"b"}, "b": {"c"}}, []string{"a", "b", "c"}, nil},
+		{"self loop", map[string][]string{"a": {"a"}}, []string{"a"}, [][]string{{"a"}}},
+		{"two nodes", map[string][]string{"a": {"b"}, "b": {"a"}}, []string{"a", "b"}, [][]string{{"a", "b"}}},
+		{"started inside", map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}}, []string{"a"}, [][]string{{"b", "c"}}},
+		{"diamond", map[string][]string{"a": {"b", "c"}, "b": {"d"}, "c": {"d"}}, []string{"a"}, nil},
+		{"disjoint", map[string][]string{"a": {"b"}, "b": {"a"}, "x": {"y"}, "y": {"x"}}, []string{"a", "x"},
+			[][]string{{"a", "b"}, {"x", "y"}}},
+		{"shared node", map[string][]string{"a": {"b", "c"}, "b": {"a"}, "c": {"a"}}, []string{"a"},
+			[][]string{{"a", "b"}, {"a", "c"}}},
+		{"unreachable start", map[string][]string{"a": {"b"}, "b": {"a"}}, []string{"x"}, nil},
+	}
+	for _, tt := range tests {
+		if cycles := findCycles(tt.graph, tt.nodes); !reflect.DeepEqual(cycles, tt.cycles) {
+			t.Errorf("%s: findCycles = %q, want %q", tt.name, cycles, tt.cycles)
+		}
+	}
+}
+
+func TestCycleKey(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		same bool
+	}{
+		{[]string{"a", "b", "c"}, []string{"b", "c", "a"}, true},
+		{[]string{"a", "b", "c"}, []string{"c", "a", "b"}, true},
+		{[]string{"a", "b"}, []string{"a", "c"}, false},
+		{[]string{"a"}, []string{"a", "a"}, false},
+	}
+	for _, tt := range tests {
+		if same := cycleKey(tt.a) == cycleKey(tt.b); same != tt.same {
+			t.Errorf("cycleKey(%q) == cycleKey(%q) is %t, want %t", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package emailctl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DomainMerge is a plan for merging the accounts and aliases of one domain into another.
+type DomainMerge struct {
+	// From is the merged domain.
+	From *DomainState
+	// Into is the domain From is merged into.
+	Into *DomainState
+	// Moves are the moves of the accounts of From. Moves of accounts whose name collides
+	// with an account or alias of Into have Existing set and only forward mail to it.
+	Moves []*AccountMove
+	// Aliases are the names of the aliases of From which are recreated in Into. Their
+	// recipients in From are replaced with an alias forwarding mail to Into.
+	Aliases []string
+	// AccountAliases are the names of the aliases of From which have the same name as an
+	// account of From. They are recreated in Into as aliases of the moved account and
+	// replaced in From by the forwarding alias of the account.
+	AccountAliases []string
+	// KeptAliases are the names of the aliases of From which are kept as they are,
+	// because Into has an account, or for AccountAliases an address, with the same name.
+	KeptAliases []string
+}
+
+// PlanDomainMerge plans merging domain 'from' into domain 'into'. The state must
+// include the BCCs.
+func (s *ServerState) PlanDomainMerge(from, into string) (*DomainMerge, error) {
+	if strings.EqualFold(from, into) {
+		return nil, fmt.Errorf("can't merge domain '%s' into itself", from)
+	}
+	p := &DomainMerge{From: s.FindDomain(from), Into: s.FindDomain(into)}
+	if p.From == nil {
+		return nil, fmt.Errorf("domain '%s' does not exist", from)
+	}
+	if p.Into == nil {
+		return nil, fmt.Errorf("domain '%s' does not exist", into)
+	}
+
+	accounts := make(map[string]bool)
+	for _, a := range p.From.Accounts {
+		accounts[strings.ToLower(a.Username)] = true
+	}
+	seen := make(map[string]bool)
+	for _, a := range p.From.Aliases {
+		key := strings.ToLower(a.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if accounts[key] {
+			if p.Into.hasAddress(a.Name) {
+				p.KeptAliases = append(p.KeptAliases, a.Name)
+			} else {
+				p.AccountAliases = append(p.AccountAliases, a.Name)
+			}
+			continue
+		}
+		if _, account := s.FindAccount(fmt.Sprintf("%s@%s", a.Name, p.Into.Name)); account != nil {
+			p.KeptAliases = append(p.KeptAliases, a.Name)
+		} else {
+			p.Aliases = append(p.Aliases, a.Name)
+		}
+	}
+
+	for _, a := range p.From.Accounts {
+		email := fmt.Sprintf("%s@%s", a.Username, p.From.Name)
+		m := &AccountMove{
+			Domain:      p.From.Name,
+			Username:    a.Username,
+			NewDomain:   p.Into.Name,
+			NewUsername: a.Username,
+			Account:     a,
+			Forward:     true,
+			Existing:    p.Into.hasAddress(a.Username),
+			rewrite:     p.Rewrite,
+		}
+		for _, ref := range s.ReferencesTo(email) {
+			// The moved aliases and the BCCs of the accounts of From are recreated
+			// in Into with rewritten recipients.
+			if !strings.EqualFold(ref.Domain, p.From.Name) || ref.Kind == AliasReference && !p.isMovedAlias(ref.Name) {
+				m.References = append(m.References, ref)
+			}
+		}
+		p.Moves = append(p.Moves, m)
+	}
+	return p, nil
+}
+
+// isMovedAlias reports whether the aliases with the given name are recreated in Into.
+func (p *DomainMerge) isMovedAlias(name string) bool {
+	for _, names := range [][]string{p.Aliases, p.AccountAliases} {
+		for _, a := range names {
+			if strings.EqualFold(a, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Rewrite returns the address in Into for an address of an account or moved alias of
+// From. Other addresses are returned unchanged.
+func (p *DomainMerge) Rewrite(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i < 0 || !strings.EqualFold(email[i+1:], p.From.Name) {
+		return email
+	}
+	name := email[:i]
+	for _, a := range p.From.Accounts {
+		if strings.EqualFold(a.Username, name) {
+			return fmt.Sprintf("%s@%s", name, p.Into.Name)
+		}
+	}
+	if p.isMovedAlias(name) {
+		return fmt.Sprintf("%s@%s", name, p.Into.Name)
+	}
+	return email
+}
+
+// Collisions returns the moves of the accounts whose name collides with an address of Into.
+func (p *DomainMerge) Collisions() []*AccountMove {
+	var moves []*AccountMove
+	for _, m := range p.Moves {
+		if m.Existing {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// Summary returns a short description of the merge.
+func (p *DomainMerge) Summary() []string {
+	collisions := p.Collisions()
+	lines := []string{
+		fmt.Sprintf("%d accounts will be moved to '%s'.", len(p.Moves)-len(collisions), p.Into.Name),
+	}
+	if len(collisions) > 0 {
+		names := make([]string, len(collisions))
+		for i, m := range collisions {
+			names[i] = m.Username
+		}
+		lines = append(lines, fmt.Sprintf("%d accounts collide with addresses in '%s' and will forward mail to them: %s",
+			len(collisions), p.Into.Name, strings.Join(names, ", ")))
+	}
+	lines = append(lines, fmt.Sprintf("%d aliases will be moved to '%s'.", len(p.Aliases), p.Into.Name))
+	if len(p.AccountAliases) > 0 {
+		lines = append(lines, fmt.Sprintf("%d aliases have the name of an account and will be moved with it: %s",
+			len(p.AccountAliases), strings.Join(p.AccountAliases, ", ")))
+	}
+	if len(p.KeptAliases) > 0 {
+		lines = append(lines, fmt.Sprintf("%d aliases collide with addresses in '%s' and will be kept in '%s': %s",
+			len(p.KeptAliases), p.Into.Name, p.From.Name, strings.Join(p.KeptAliases, ", ")))
+	}
+	return lines
+}
+
+// Describe returns a line for each of the changes made by the merge.
+func (p *DomainMerge) Describe() []string {
+	var lines []string
+	for _, m := range p.Moves {
+		lines = append(lines, m.Describe()...)
+	}
+	for _, name := range p.Aliases {
+		lines = append(lines, p.describeAlias(name)...)
+		lines = append(lines, fmt.Sprintf("create alias %s@%s -> %s@%s", name, p.From.Name, name, p.Into.Name))
+	}
+	for _, name := range p.AccountAliases {
+		lines = append(lines, p.describeAlias(name)...)
+	}
+	return lines
+}
+
+// describeAlias returns a line for each of the changes made by moving the aliases with
+// the given name to Into.
+func (p *DomainMerge) describeAlias(name string) []string {
+	var lines []string
+	for _, a := range p.recipients(name) {
+		if !p.hasRecipient(name, p.Rewrite(a.Email)) {
+			lines = append(lines, fmt.Sprintf("create alias %s@%s -> %s%s", name, p.Into.Name, p.Rewrite(a.Email), DisabledSuffix(a.Enabled)))
+		}
+	}
+	for _, a := range p.recipients(name) {
+		lines = append(lines, fmt.Sprintf("remove alias %s@%s -> %s", name, p.From.Name, a.Email))
+	}
+	return lines
+}
+
+// recipients returns the aliases of From with the given name.
+func (p *DomainMerge) recipients(name string) []Alias {
+	var aliases []Alias
+	for _, a := range p.From.Aliases {
+		if strings.EqualFold(a.Name, name) {
+			aliases = append(aliases, a)
+		}
+	}
+	return aliases
+}
+
+// hasRecipient reports whether Into already has an alias with the given name and recipient.
+func (p *DomainMerge) hasRecipient(name, email string) bool {
+	for _, a := range p.Into.Aliases {
+		if strings.EqualFold(a.Name, name) && strings.EqualFold(a.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeDomain carries out the merge. The passwords of the accounts created in Into are
+// created with the generator and passed to save once all steps have succeeded. If a step
+// fails, the completed steps are rolled back.
+func (c *Client) MergeDomain(p *DomainMerge, generator *PasswordGenerator, save func(email, password string) error) error {
+	tx := NewTransaction()
+	checker := c.Aliases.newAliasChecker()
+	var credentials []credential
+	for _, m := range p.Moves {
+		var password string
+		if !m.Existing {
+			var err error
+			if password, err = generator.Generate(m.NewUsername, m.NewDomain); err != nil {
+				return tx.Rollback(fmt.Sprintf("generate a password for %s", m.NewEmail()), err)
+			}
+		}
+		if err := c.moveAccount(tx, checker, m, password); err != nil {
+			return err
+		}
+		if !m.Existing {
+			credentials = append(credentials, credential{email: m.NewEmail(), password: password})
+		}
+	}
+
+	for _, name := range p.Aliases {
+		if err := c.mergeAlias(tx, checker, p, name); err != nil {
+			return err
+		}
+		if err := c.createForward(tx, checker, p.From.Name, name, fmt.Sprintf("%s@%s", name, p.Into.Name)); err != nil {
+			return err
+		}
+	}
+	// The forwarding aliases of the moved accounts replace these aliases in From.
+	for _, name := range p.AccountAliases {
+		if err := c.mergeAlias(tx, checker, p, name); err != nil {
+			return err
+		}
+	}
+	return saveCredentials(tx, credentials, save)
+}
+
+// mergeAlias recreates the aliases of From with the given name in Into and removes them
+// from From.
+func (c *Client) mergeAlias(tx *Transaction, checker *aliasChecker, p *DomainMerge, name string) error {
+	recipients := p.recipients(name)
+	for _, a := range recipients {
+		email := p.Rewrite(a.Email)
+		if p.hasRecipient(name, email) {
+			continue
+		}
+		if err := c.copyAlias(tx, checker, p.Into.Name, name, email, a.Enabled); err != nil {
+			return err
+		}
+	}
+	for _, a := range recipients {
+		ref := Reference{Kind: AliasReference, Domain: p.From.Name, Name: a.Name, Email: a.Email, Enabled: a.Enabled}
+		if err := c.removeReference(tx, checker, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
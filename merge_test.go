@@ -0,0 +1,146 @@
+package emailctl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lyubenblagoev/goprsc"
+)
+
+// testDomainState creates the state of an enabled domain with enabled accounts and
+// aliases given as pairs of name and recipient.
+func testDomainState(name string, accounts []string, aliases ...[2]string) *DomainState {
+	d := &DomainState{Domain: Domain{&goprsc.Domain{Name: name, Enabled: true}}}
+	for _, username := range accounts {
+		d.Accounts = append(d.Accounts, &AccountState{Account: Account{&goprsc.Account{Username: username, Domain: name, Enabled: true}}})
+	}
+	for _, a := range aliases {
+		d.Aliases = append(d.Aliases, Alias{&goprsc.Alias{Name: a[0], Email: a[1], Enabled: true}})
+	}
+	return d
+}
+
+func TestPlanDomainMerge(t *testing.T) {
+	other := testDomainState("other.com", []string{"bob"}, [2]string{"team", "john@old.com"})
+	other.Accounts[0].SenderBcc = &Bcc{&goprsc.Bcc{Email: "jane@old.com", Enabled: true}}
+	state := &ServerState{Domains: []*DomainState{
+		testDomainState("old.com", []string{"john", "jane", "admin", "sam"},
+			[2]string{"info", "john@old.com"},
+			[2]string{"info", "ext@y.com"},
+			[2]string{"John", "john@old.com"},
+			[2]string{"admin", "boss@y.com"},
+			[2]string{"sales", "jane@old.com"},
+			[2]string{"postmaster", "admin@old.com"},
+			[2]string{"INFO", "jane@old.com"},
+		),
+		testDomainState("new.com", []string{"admin", "postmaster"}, [2]string{"sam", "x@y.com"}),
+		other,
+	}}
+
+	p, err := state.PlanDomainMerge("OLD.com", "new.com")
+	if err != nil {
+		t.Fatalf("PlanDomainMerge() error = %v", err)
+	}
+	if p.From.Name != "old.com" || p.Into.Name != "new.com" {
+		t.Errorf("merging %s into %s, want old.com into new.com", p.From.Name, p.Into.Name)
+	}
+	if want := []string{"info", "sales"}; !reflect.DeepEqual(p.Aliases, want) {
+		t.Errorf("Aliases = %v, want %v", p.Aliases, want)
+	}
+	if want := []string{"John"}; !reflect.DeepEqual(p.AccountAliases, want) {
+		t.Errorf("AccountAliases = %v, want %v", p.AccountAliases, want)
+	}
+	if want := []string{"admin", "postmaster"}; !reflect.DeepEqual(p.KeptAliases, want) {
+		t.Errorf("KeptAliases = %v, want %v", p.KeptAliases, want)
+	}
+
+	moves := []struct {
+		username   string
+		existing   bool
+		references []string
+	}{
+		{"john", false, []string{"alias team@other.com -> john@old.com"}},
+		{"jane", false, []string{"sender-bcc bob@other.com -> jane@old.com"}},
+		{"admin", true, []string{"alias postmaster@old.com -> admin@old.com"}},
+		{"sam", true, nil},
+	}
+	if len(p.Moves) != len(moves) {
+		t.Fatalf("%d moves, want %d", len(p.Moves), len(moves))
+	}
+	for i, want := range moves {
+		m := p.Moves[i]
+		if m.Username != want.username || m.NewEmail() != want.username+"@new.com" || !m.Forward {
+			t.Errorf("move %d = %s -> %s (forward %t), want %s@old.com -> %s@new.com with forward",
+				i, m.Email(), m.NewEmail(), m.Forward, want.username, want.username)
+			continue
+		}
+		if m.Existing != want.existing {
+			t.Errorf("move of %s: Existing = %t, want %t", m.Username, m.Existing, want.existing)
+		}
+		var refs []string
+		for _, ref := range m.References {
+			refs = append(refs, ref.String())
+		}
+		if !reflect.DeepEqual(refs, want.references) {
+			t.Errorf("move of %s: References = %v, want %v", m.Username, refs, want.references)
+		}
+	}
+
+	if got := p.Collisions(); len(got) != 2 || got[0].Username != "admin" || got[1].Username != "sam" {
+		t.Errorf("Collisions() = %v, want the moves of admin and sam", got)
+	}
+
+	rewrites := map[string]string{
+		"john@old.com":       "john@new.com",
+		"JOHN@OLD.COM":       "JOHN@new.com",
+		"admin@old.com":      "admin@new.com",
+		"info@old.com":       "info@new.com",
+		"sales@old.com":      "sales@new.com",
+		"postmaster@old.com": "postmaster@old.com",
+		"nobody@old.com":     "nobody@old.com",
+		"ext@y.com":          "ext@y.com",
+		"john":               "john",
+	}
+	for email, want := range rewrites {
+		if got := p.Rewrite(email); got != want {
+			t.Errorf("Rewrite(%s) = %s, want %s", email, got, want)
+		}
+	}
+
+	describe := strings.Join(p.Describe(), "\n")
+	for _, line := range []string{
+		"create alias info@new.com -> john@new.com",
+		"create alias info@new.com -> ext@y.com",
+		"create alias info@new.com -> jane@new.com",
+		"remove alias info@old.com -> jane@old.com",
+		"create alias info@old.com -> info@new.com",
+		"create alias John@new.com -> john@new.com",
+		"remove alias John@old.com -> john@old.com",
+	} {
+		if !strings.Contains(describe, line) {
+			t.Errorf("Describe() doesn't contain %q:\n%s", line, describe)
+		}
+	}
+	for _, kept := range []string{"postmaster@old.com ->", "admin@old.com -> boss@y.com"} {
+		if strings.Contains(describe, "remove alias "+kept) {
+			t.Errorf("Describe() removes the kept alias %s:\n%s", kept, describe)
+		}
+	}
+}
+
+func TestPlanDomainMergeErrors(t *testing.T) {
+	state := &ServerState{Domains: []*DomainState{
+		testDomainState("old.com", nil),
+		testDomainState("new.com", nil),
+	}}
+	for _, domains := range [][2]string{
+		{"old.com", "OLD.COM"},
+		{"missing.com", "new.com"},
+		{"old.com", "missing.com"},
+	} {
+		if _, err := state.PlanDomainMerge(domains[0], domains[1]); err == nil {
+			t.Errorf("PlanDomainMerge(%s, %s) succeeded, want an error", domains[0], domains[1])
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package emailctl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AccountMove is a plan for moving an account to another domain or username.
+type AccountMove struct {
+	// Domain and Username identify the source account.
+	Domain   string
+	Username string
+	// NewDomain and NewUsername identify the account created in place of the source.
+	NewDomain   string
+	NewUsername string
+	// Account is the source account with its BCCs.
+	Account *AccountState
+	// Aliases are the aliases of the source domain forwarding mail to the source account,
+	// which are recreated in the target domain.
+	Aliases []Alias
+	// References are the aliases and BCCs referencing the source account, which are
+	// changed to point to the new account.
+	References []Reference
+	// DeleteSource deletes the source account instead of disabling it.
+	DeleteSource bool
+	// Forward creates an alias forwarding mail for the source address to the new account.
+	Forward bool
+	// Existing reports that the target account already exists. Only the references are
+	// changed, and the forwarding alias created, for such moves.
+	Existing bool
+
+	// rewrite, if set, returns the address the BCCs of the new account point to.
+	rewrite func(email string) string
+}
+
+// PlanAccountMove plans moving the account username@domain to newUsername@newDomain.
+// The state must include the BCCs. By default, the source account is disabled and an
+// alias forwarding mail to the new account is created in its place.
+func (s *ServerState) PlanAccountMove(domain, username, newDomain, newUsername string) (*AccountMove, error) {
+	if newUsername == "" {
+		newUsername = username
+	}
+	if err := ValidateEmailFromParts(newUsername, newDomain); err != nil {
+		return nil, err
+	}
+
+	email := fmt.Sprintf("%s@%s", username, domain)
+	newEmail := fmt.Sprintf("%s@%s", newUsername, newDomain)
+	source, account := s.FindAccount(email)
+	if account == nil {
+		return nil, fmt.Errorf("account '%s' does not exist", email)
+	}
+	target, existing := s.FindAccount(newEmail)
+	if target == nil {
+		return nil, fmt.Errorf("domain '%s' does not exist", newDomain)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("account '%s' already exists", newEmail)
+	}
+
+	m := &AccountMove{
+		Domain:      source.Name,
+		Username:    account.Username,
+		NewDomain:   target.Name,
+		NewUsername: newUsername,
+		Account:     account,
+		Forward:     true,
+	}
+	for _, a := range source.Aliases {
+		if strings.EqualFold(a.Email, email) && !target.hasAddress(a.Name) {
+			m.Aliases = append(m.Aliases, a)
+		}
+	}
+	for _, ref := range s.ReferencesTo(email) {
+		own := ref.Kind != AliasReference && strings.EqualFold(ref.Domain, domain) && strings.EqualFold(ref.Name, username)
+		if !own {
+			m.References = append(m.References, ref)
+		}
+	}
+	return m, nil
+}
+
+// hasAddress reports whether the domain has an account or alias with the given name.
+func (d *DomainState) hasAddress(name string) bool {
+	for _, a := range d.Addresses() {
+		if strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Email returns the address of the source account.
+func (m *AccountMove) Email() string {
+	return fmt.Sprintf("%s@%s", m.Username, m.Domain)
+}
+
+// NewEmail returns the address of the new account.
+func (m *AccountMove) NewEmail() string {
+	return fmt.Sprintf("%s@%s", m.NewUsername, m.NewDomain)
+}
+
+// Describe returns a line for each of the changes made by the move.
+func (m *AccountMove) Describe() []string {
+	newEmail := m.NewEmail()
+	var lines []string
+	if !m.Existing {
+		lines = append(lines, fmt.Sprintf("create account %s", newEmail))
+		if !m.Account.Enabled {
+			lines = append(lines, fmt.Sprintf("disable account %s", newEmail))
+		}
+		if bcc := m.Account.SenderBcc; bcc != nil {
+			lines = append(lines, fmt.Sprintf("create sender-bcc %s -> %s", newEmail, m.bccEmail(bcc)))
+		}
+		if bcc := m.Account.RecipientBcc; bcc != nil {
+			lines = append(lines, fmt.Sprintf("create recipient-bcc %s -> %s", newEmail, m.bccEmail(bcc)))
+		}
+	}
+	for _, a := range m.Aliases {
+		lines = append(lines, fmt.Sprintf("create alias %s@%s -> %s", a.Name, m.NewDomain, newEmail))
+	}
+	for _, ref := range m.References {
+		lines = append(lines, fmt.Sprintf("change %s to %s", ref.String(), newEmail))
+	}
+	if m.Forward {
+		lines = append(lines, fmt.Sprintf("create alias %s -> %s", m.Email(), newEmail))
+	}
+	if m.DeleteSource {
+		lines = append(lines, fmt.Sprintf("delete account %s", m.Email()))
+	} else if m.Account.Enabled {
+		lines = append(lines, fmt.Sprintf("disable account %s", m.Email()))
+	}
+	return lines
+}
+
+// bccEmail returns the email address of the BCC of the new account, which points to
+// the new account instead of the source account.
+func (m *AccountMove) bccEmail(bcc *Bcc) string {
+	if m.rewrite != nil {
+		return m.rewrite(bcc.Email)
+	}
+	if strings.EqualFold(bcc.Email, m.Email()) {
+		return m.NewEmail()
+	}
+	return bcc.Email
+}
+
+// MoveAccount carries out the move, creating the new account with the given password.
+// If a step fails, the completed steps are rolled back. A deleted source account can't
+// be restored, so it is deleted last.
+func (c *Client) MoveAccount(m *AccountMove, password string) error {
+	return c.moveAccount(NewTransaction(), c.Aliases.newAliasChecker(), m, password)
+}
+
+func (c *Client) moveAccount(tx *Transaction, checker *aliasChecker, m *AccountMove, password string) error {
+	if !m.Existing {
+		if err := c.createMovedAccount(tx, m, password); err != nil {
+			return err
+		}
+	}
+
+	newEmail := m.NewEmail()
+	for _, a := range m.Aliases {
+		if err := c.copyAlias(tx, checker, m.NewDomain, a.Name, newEmail, a.Enabled); err != nil {
+			return err
+		}
+	}
+	for _, ref := range m.References {
+		if err := c.retargetReference(tx, checker, ref, newEmail); err != nil {
+			return err
+		}
+	}
+
+	if m.Forward {
+		if err := c.createForward(tx, checker, m.Domain, m.Username, newEmail); err != nil {
+			return err
+		}
+	}
+	if m.DeleteSource {
+		return tx.Do(fmt.Sprintf("delete account %s", m.Email()),
+			func() error {
+				return c.Accounts.Delete(m.Domain, m.Username)
+			}, nil)
+	}
+	if !m.Account.Enabled {
+		return nil
+	}
+	return tx.Do(fmt.Sprintf("disable account %s", m.Email()),
+		func() error {
+			return c.Accounts.Disable(m.Domain, m.Username)
+		},
+		func() error {
+			return c.Accounts.Enable(m.Domain, m.Username)
+		})
+}
+
+// createMovedAccount creates the target account of the move with the BCCs of the source account.
+func (c *Client) createMovedAccount(tx *Transaction, m *AccountMove, password string) error {
+	newEmail := m.NewEmail()
+	err := tx.Do(fmt.Sprintf("create account %s", newEmail),
+		func() error {
+			return c.Accounts.Create(m.NewDomain, m.NewUsername, password)
+		},
+		func() error {
+			return c.Accounts.Delete(m.NewDomain, m.NewUsername)
+		})
+	if err != nil {
+		return err
+	}
+	if !m.Account.Enabled {
+		err := tx.Do(fmt.Sprintf("disable account %s", newEmail),
+			func() error {
+				return c.Accounts.Disable(m.NewDomain, m.NewUsername)
+			}, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	bccs := []struct {
+		kind string
+		bcc  *Bcc
+	}{
+		{SenderBccReference, m.Account.SenderBcc},
+		{RecipientBccReference, m.Account.RecipientBcc},
+	}
+	for _, b := range bccs {
+		if b.bcc == nil {
+			continue
+		}
+		if err := c.copyBcc(tx, b.kind, m.NewDomain, m.NewUsername, m.bccEmail(b.bcc), b.bcc.Enabled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBcc creates a BCC of the given kind with the given enabled state as a step of the transaction.
+func (c *Client) copyBcc(tx *Transaction, kind, domain, username, email string, enabled bool) error {
+	service := c.bccService(kind)
+	return tx.Do(fmt.Sprintf("create %s %s@%s -> %s", kind, username, domain, email),
+		func() error {
+			if err := service.Create(domain, username, email); err != nil {
+				return err
+			}
+			if !enabled {
+				return service.Disable(domain, username)
+			}
+			return nil
+		},
+		func() error {
+			return service.Delete(domain, username)
+		})
+}
+
+// copyAlias creates an alias with the given enabled state as a step of the transaction.
+// Enabled aliases are checked with the checker.
+func (c *Client) copyAlias(tx *Transaction, checker *aliasChecker, domain, name, email string, enabled bool) error {
+	return tx.Do(fmt.Sprintf("create alias %s@%s -> %s", name, domain, email),
+		func() error {
+			if enabled {
+				if err := checker.check(domain, name, email); err != nil {
+					return err
+				}
+			}
+			if err := c.Aliases.create(domain, name, email); err != nil {
+				checker.remove(domain, name, email)
+				return err
+			}
+			if !enabled {
+				return c.Aliases.Disable(domain, name, email)
+			}
+			return nil
+		},
+		func() error {
+			return c.Aliases.Delete(domain, name, email)
+		})
+}
@@ -16,13 +16,19 @@ const (
 // ReadAndConfirmPassword reads a password and confirmation from the terminal.
 // Retries three times if the passwords do not match.
 func ReadAndConfirmPassword() (string, error) {
+	return ReadAndConfirmSecret("Password: ", "Confirm password: ")
+}
+
+// ReadAndConfirmSecret reads a secret and its confirmation from the terminal using
+// the given prompts. Retries three times if the secrets do not match.
+func ReadAndConfirmSecret(prompt, confirmPrompt string) (string, error) {
 	for i := 0; i < maxPromptRetries; i++ {
-		pass, err := ReadPassword("Password: ")
+		pass, err := ReadPassword(prompt)
 		if err != nil {
 			return "", err
 		}
 
-		confirmPass, err := ReadPassword("Confirm password: ")
+		confirmPass, err := ReadPassword(confirmPrompt)
 		if err != nil {
 			return "", err
 		}
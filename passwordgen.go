@@ -0,0 +1,233 @@
+package emailctl
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordMode selects the kind of passwords produced by a PasswordGenerator.
+type PasswordMode string
+
+const (
+	// RandomPasswordMode generates passwords of random characters.
+	RandomPasswordMode PasswordMode = "random"
+	// DicewarePasswordMode generates passphrases of random words.
+	DicewarePasswordMode PasswordMode = "diceware"
+
+	maxGenerateAttempts = 100
+
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!#$%&*+-./:=?@^_~"
+)
+
+// PasswordGenerator generates passwords that satisfy a PasswordPolicy.
+type PasswordGenerator struct {
+	// Policy is the policy generated passwords must satisfy. May be nil.
+	Policy *PasswordPolicy
+	// Mode is the generation mode. Defaults to RandomPasswordMode.
+	Mode PasswordMode
+	// Length is the number of characters in random mode. When zero, the length is
+	// derived from the policy.
+	Length int
+	// Words is the number of words in diceware mode. When zero, the number of words
+	// is derived from the policy.
+	Words int
+	// Separator is placed between the words in diceware mode.
+	Separator string
+	// Wordlist is the list of words used in diceware mode. Defaults to the built-in list.
+	Wordlist []string
+}
+
+// NewPasswordGenerator creates a PasswordGenerator for the given policy with default settings.
+func NewPasswordGenerator(policy *PasswordPolicy) *PasswordGenerator {
+	return &PasswordGenerator{
+		Policy:    policy,
+		Mode:      RandomPasswordMode,
+		Separator: "-",
+	}
+}
+
+// Generate generates a password for the account 'username'@'domain' that satisfies the
+// generator's policy. The username and domain may be empty.
+func (g *PasswordGenerator) Generate(username, domain string) (string, error) {
+	for i := 0; i < maxGenerateAttempts; i++ {
+		var password string
+		var err error
+		switch g.Mode {
+		case RandomPasswordMode, "":
+			password, err = g.generateRandom()
+		case DicewarePasswordMode:
+			password, err = g.generateDiceware()
+		default:
+			return "", fmt.Errorf("unknown password mode: '%s'", g.Mode)
+		}
+		if err != nil {
+			return "", err
+		}
+		if g.Policy.Validate(password, username, domain) == nil {
+			return password, nil
+		}
+	}
+	return "", errors.New("unable to generate a password that satisfies the password policy")
+}
+
+func (g *PasswordGenerator) generateRandom() (string, error) {
+	length := g.Length
+	if length == 0 {
+		length = 16
+		if g.Policy != nil {
+			pool := float64(len(lowerChars) + len(upperChars) + len(digitChars) + len(symbolChars))
+			if n := int(math.Ceil(g.Policy.MinEntropy / math.Log2(pool))); n > length {
+				length = n
+			}
+			if g.Policy.MinLength > length {
+				length = g.Policy.MinLength
+			}
+		}
+	}
+
+	// Start with one character from each class so class requirements are always met.
+	classes := []string{lowerChars, upperChars, digitChars, symbolChars}
+	var chars []byte
+	for _, c := range classes {
+		if len(chars) == length {
+			break
+		}
+		b, err := randomChar(c)
+		if err != nil {
+			return "", err
+		}
+		chars = append(chars, b)
+	}
+	all := strings.Join(classes, "")
+	for len(chars) < length {
+		b, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		chars = append(chars, b)
+	}
+
+	for i := len(chars) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		chars[i], chars[j] = chars[j], chars[i]
+	}
+	return string(chars), nil
+}
+
+func (g *PasswordGenerator) generateDiceware() (string, error) {
+	wordlist := g.Wordlist
+	if len(wordlist) == 0 {
+		wordlist = defaultWordlist
+	}
+	if len(wordlist) < 2 {
+		return "", errors.New("the word list must contain at least two words")
+	}
+
+	count := g.Words
+	if count == 0 {
+		count = 5
+		if g.Policy != nil {
+			if n := int(math.Ceil(g.Policy.MinEntropy / math.Log2(float64(len(wordlist))))); n > count {
+				count = n
+			}
+		}
+	}
+
+	words := make([]string, count)
+	for i := range words {
+		n, err := randomInt(len(wordlist))
+		if err != nil {
+			return "", err
+		}
+		words[i] = wordlist[n]
+	}
+
+	if g.Policy != nil {
+		if g.Policy.RequireUpper {
+			i, err := randomInt(count)
+			if err != nil {
+				return "", err
+			}
+			r, size := utf8.DecodeRuneInString(words[i])
+			words[i] = string(unicode.ToUpper(r)) + words[i][size:]
+		}
+		if g.Policy.RequireDigit {
+			i, err := randomInt(count)
+			if err != nil {
+				return "", err
+			}
+			d, err := randomChar(digitChars)
+			if err != nil {
+				return "", err
+			}
+			words[i] += string(d)
+		}
+		if g.Policy.RequireSymbol && strings.Trim(g.Separator, lowerChars+upperChars+digitChars) == "" {
+			i, err := randomInt(count)
+			if err != nil {
+				return "", err
+			}
+			s, err := randomChar(symbolChars)
+			if err != nil {
+				return "", err
+			}
+			words[i] += string(s)
+		}
+	}
+
+	return strings.Join(words, g.Separator), nil
+}
+
+// ReadWordlist reads a word list from the specified file. Each non-empty line holds
+// a word; lines in diceware format ("11111 word") use the last field as the word.
+func ReadWordlist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		words = append(words, fields[len(fields)-1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	n, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[n], nil
+}
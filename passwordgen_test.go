@@ -0,0 +1,73 @@
+package emailctl
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func TestPasswordGeneratorSatisfiesPolicy(t *testing.T) {
+	policy := &PasswordPolicy{
+		MinLength:     12,
+		RequireLower:  true,
+		RequireUpper:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		MinEntropy:    60,
+	}
+	tests := []struct {
+		name      string
+		generator *PasswordGenerator
+	}{
+		{"random", &PasswordGenerator{Policy: policy, Mode: RandomPasswordMode}},
+		{"random with length", &PasswordGenerator{Policy: policy, Mode: RandomPasswordMode, Length: 20}},
+		{"diceware", &PasswordGenerator{Policy: policy, Mode: DicewarePasswordMode, Separator: "-"}},
+		{"diceware non-ASCII", &PasswordGenerator{Policy: policy, Mode: DicewarePasswordMode, Separator: "-",
+			Wordlist: []string{"éclair", "über", "ärger", "ödland", "ìsola", "ñandú"}}},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			password, err := tt.generator.Generate("john", "example.com")
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if err := policy.Validate(password, "john", "example.com"); err != nil {
+				t.Errorf("%s: generated password %q: %v", tt.name, password, err)
+			}
+			if !utf8.ValidString(password) {
+				t.Errorf("%s: generated password %q is not valid UTF-8", tt.name, password)
+			}
+			if tt.generator.Length > 0 && len(password) != tt.generator.Length {
+				t.Errorf("%s: generated password %q has length %d, want %d", tt.name, password, len(password), tt.generator.Length)
+			}
+		}
+	}
+}
+
+func TestDicewareCapitalizesFirstRune(t *testing.T) {
+	g := &PasswordGenerator{
+		Policy:    &PasswordPolicy{RequireUpper: true},
+		Mode:      DicewarePasswordMode,
+		Words:     3,
+		Separator: " ",
+		Wordlist:  []string{"éclair", "über"},
+	}
+	password, err := g.Generate("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upper := 0
+	for _, word := range strings.Split(password, " ") {
+		r, _ := utf8.DecodeRuneInString(word)
+		if unicode.IsUpper(r) {
+			upper++
+		}
+		if word != "éclair" && word != "über" && word != "Éclair" && word != "Über" {
+			t.Errorf("unexpected word %q in %q", word, password)
+		}
+	}
+	if upper != 1 {
+		t.Errorf("%q has %d capitalized words, want 1", password, upper)
+	}
+}
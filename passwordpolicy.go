@@ -0,0 +1,193 @@
+package emailctl
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/viper"
+)
+
+// PasswordPolicy describes the requirements a password must satisfy before it
+// is sent to the Postfix REST Server.
+type PasswordPolicy struct {
+	// MinLength is the minimum number of characters in the password.
+	MinLength int
+	// RequireLower requires at least one lower case letter.
+	RequireLower bool
+	// RequireUpper requires at least one upper case letter.
+	RequireUpper bool
+	// RequireDigit requires at least one digit.
+	RequireDigit bool
+	// RequireSymbol requires at least one character that is neither a letter nor a digit.
+	RequireSymbol bool
+	// ForbidUsername rejects passwords containing the account username.
+	ForbidUsername bool
+	// ForbidDomain rejects passwords containing the domain name or its first label.
+	ForbidDomain bool
+	// MinEntropy is the minimum estimated entropy of the password in bits.
+	MinEntropy float64
+}
+
+// PasswordPolicyError is returned when a password does not satisfy a PasswordPolicy.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return fmt.Sprintf("password does not satisfy the password policy: %s", strings.Join(e.Violations, "; "))
+}
+
+// DefaultPasswordPolicy returns the policy used when no password settings are configured.
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:      10,
+		ForbidUsername: true,
+		ForbidDomain:   true,
+		MinEntropy:     40,
+	}
+}
+
+// LoadPasswordPolicy builds a PasswordPolicy from the 'password' section of the
+// configuration, using the default policy for missing settings.
+func LoadPasswordPolicy() *PasswordPolicy {
+	p := DefaultPasswordPolicy()
+	if viper.IsSet("password.minLength") {
+		p.MinLength = viper.GetInt("password.minLength")
+	}
+	if viper.IsSet("password.requireLower") {
+		p.RequireLower = viper.GetBool("password.requireLower")
+	}
+	if viper.IsSet("password.requireUpper") {
+		p.RequireUpper = viper.GetBool("password.requireUpper")
+	}
+	if viper.IsSet("password.requireDigit") {
+		p.RequireDigit = viper.GetBool("password.requireDigit")
+	}
+	if viper.IsSet("password.requireSymbol") {
+		p.RequireSymbol = viper.GetBool("password.requireSymbol")
+	}
+	if viper.IsSet("password.forbidUsername") {
+		p.ForbidUsername = viper.GetBool("password.forbidUsername")
+	}
+	if viper.IsSet("password.forbidDomain") {
+		p.ForbidDomain = viper.GetBool("password.forbidDomain")
+	}
+	if viper.IsSet("password.minEntropy") {
+		p.MinEntropy = viper.GetFloat64("password.minEntropy")
+	}
+	return p
+}
+
+// Validate checks the password against the policy. The username and domain are
+// used for the substring checks and may be empty. A nil policy accepts any password.
+func (p *PasswordPolicy) Validate(password, username, domain string) error {
+	if p == nil {
+		return nil
+	}
+
+	var violations []string
+	if n := len([]rune(password)); n < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	classes := characterClasses(password)
+	if p.RequireLower && !classes.lower && !classes.otherLower {
+		violations = append(violations, "must contain a lower case letter")
+	}
+	if p.RequireUpper && !classes.upper && !classes.otherUpper {
+		violations = append(violations, "must contain an upper case letter")
+	}
+	if p.RequireDigit && !classes.digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !classes.symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	lower := strings.ToLower(password)
+	if p.ForbidUsername && len(username) > 0 && strings.Contains(lower, strings.ToLower(username)) {
+		violations = append(violations, "must not contain the username")
+	}
+	if p.ForbidDomain && len(domain) > 0 {
+		domain = strings.ToLower(domain)
+		label := strings.Split(domain, ".")[0]
+		if strings.Contains(lower, domain) || (len(label) > 2 && strings.Contains(lower, label)) {
+			violations = append(violations, "must not contain the domain name")
+		}
+	}
+
+	if entropy := EstimateEntropy(password); entropy < p.MinEntropy {
+		violations = append(violations, fmt.Sprintf("estimated entropy %.0f bits is below the required %.0f bits", entropy, p.MinEntropy))
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+type classSet struct {
+	lower, upper, digit, symbol, other bool
+	// otherLower and otherUpper report non-ASCII letters. They count for the case
+	// requirements, but belong to the 'other' pool of the entropy estimate.
+	otherLower, otherUpper bool
+}
+
+func characterClasses(s string) classSet {
+	var c classSet
+	for _, r := range s {
+		switch {
+		case r > unicode.MaxASCII:
+			c.other = true
+			c.otherLower = c.otherLower || unicode.IsLower(r)
+			c.otherUpper = c.otherUpper || unicode.IsUpper(r)
+		case unicode.IsLower(r):
+			c.lower = true
+		case unicode.IsUpper(r):
+			c.upper = true
+		case unicode.IsDigit(r):
+			c.digit = true
+		default:
+			c.symbol = true
+		}
+	}
+	return c
+}
+
+// EstimateEntropy returns a rough estimate of the password entropy in bits.
+// The estimate is based on the size of the character pool the password draws
+// from; characters repeating their predecessor do not add to the estimate.
+func EstimateEntropy(password string) float64 {
+	classes := characterClasses(password)
+	pool := 0
+	if classes.lower {
+		pool += 26
+	}
+	if classes.upper {
+		pool += 26
+	}
+	if classes.digit {
+		pool += 10
+	}
+	if classes.symbol {
+		pool += 33
+	}
+	if classes.other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	length := 0
+	var prev rune
+	for i, r := range []rune(password) {
+		if i == 0 || r != prev {
+			length++
+		}
+		prev = r
+	}
+	return float64(length) * math.Log2(float64(pool))
+}
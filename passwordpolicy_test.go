@@ -0,0 +1,83 @@
+package emailctl
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEstimateEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		want     float64
+	}{
+		{"", 0},
+		{"abc", 3 * math.Log2(26)},
+		{"aaa", math.Log2(26)},
+		{"abab", 4 * math.Log2(26)},
+		{"Ab1!", 4 * math.Log2(26+26+10+33)},
+		{"12345", 5 * math.Log2(10)},
+		{"é", math.Log2(100)},
+		{"aé", 2 * math.Log2(126)},
+	}
+	for _, tt := range tests {
+		if got := EstimateEntropy(tt.password); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("EstimateEntropy(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	strict := &PasswordPolicy{
+		MinLength:      8,
+		RequireLower:   true,
+		RequireUpper:   true,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		ForbidUsername: true,
+		ForbidDomain:   true,
+	}
+	tests := []struct {
+		name       string
+		policy     *PasswordPolicy
+		password   string
+		username   string
+		domain     string
+		violations []string
+	}{
+		{"nil policy", nil, "", "john", "example.com", nil},
+		{"valid", strict, "Xq7!rT2#", "john", "example.com", nil},
+		{"too short", strict, "Xq7!rT", "", "", []string{"must be at least 8 characters long"}},
+		{"length counts runes", &PasswordPolicy{MinLength: 3}, "äöü", "", "", nil},
+		{"missing classes", strict, "qwertyuiop", "", "", []string{
+			"must contain an upper case letter",
+			"must contain a digit",
+			"must contain a symbol",
+		}},
+		{"non-ASCII letters count for case", &PasswordPolicy{RequireLower: true, RequireUpper: true}, "Ölçü", "", "", nil},
+		{"only upper", strict, "QWERTYU1!", "", "", []string{"must contain a lower case letter"}},
+		{"contains username", strict, "xJohn7!yz", "john", "", []string{"must not contain the username"}},
+		{"contains domain", strict, "Ab1!example.com", "", "Example.com", []string{"must not contain the domain name"}},
+		{"contains domain label", strict, "Ab1!Example", "", "example.com", []string{"must not contain the domain name"}},
+		{"short label allowed", strict, "Ab1!xyzqwe", "", "xy.com", nil},
+		{"username check disabled", &PasswordPolicy{}, "john", "john", "", nil},
+		{"low entropy", &PasswordPolicy{MinEntropy: 40}, "aaaaaaaaaaaa", "", "", []string{
+			"estimated entropy 5 bits is below the required 40 bits",
+		}},
+	}
+	for _, tt := range tests {
+		err := tt.policy.Validate(tt.password, tt.username, tt.domain)
+		var violations []string
+		if err != nil {
+			perr, ok := err.(*PasswordPolicyError)
+			if !ok {
+				t.Errorf("%s: error %v is not a *PasswordPolicyError", tt.name, err)
+				continue
+			}
+			violations = perr.Violations
+		}
+		if !reflect.DeepEqual(violations, tt.violations) {
+			t.Errorf("%s: violations = %q, want %q", tt.name, violations, tt.violations)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package emailctl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// removeReference removes the alias or BCC holding the reference as a step of the transaction.
+func (c *Client) removeReference(tx *Transaction, checker *aliasChecker, ref Reference) error {
+	description := fmt.Sprintf("remove %s", ref.String())
+	switch ref.Kind {
+	case AliasReference:
+		return tx.Do(description,
+			func() error {
+				if err := c.Aliases.Delete(ref.Domain, ref.Name, ref.Email); err != nil {
+					return err
+				}
+				checker.remove(ref.Domain, ref.Name, ref.Email)
+				return nil
+			},
+			func() error {
+				if err := c.Aliases.create(ref.Domain, ref.Name, ref.Email); err != nil {
+					return err
+				}
+				if !ref.Enabled {
+					return c.Aliases.Disable(ref.Domain, ref.Name, ref.Email)
+				}
+				return nil
+			})
+	default:
+		service := c.bccService(ref.Kind)
+		return tx.Do(description,
+			func() error {
+				return service.Delete(ref.Domain, ref.Name)
+			},
+			func() error {
+				if err := service.Create(ref.Domain, ref.Name, ref.Email); err != nil {
+					return err
+				}
+				if !ref.Enabled {
+					return service.Disable(ref.Domain, ref.Name)
+				}
+				return nil
+			})
+	}
+}
+
+// retargetReference changes the email address of the alias or BCC holding the reference
+// to newEmail as a step of the transaction. Changed aliases are checked with the checker.
+func (c *Client) retargetReference(tx *Transaction, checker *aliasChecker, ref Reference, newEmail string) error {
+	description := fmt.Sprintf("change %s to %s", ref.String(), newEmail)
+	switch ref.Kind {
+	case AliasReference:
+		return tx.Do(description,
+			func() error {
+				return c.Aliases.changeRecipient(ref.Domain, ref.Name, ref.Email, newEmail, checker)
+			},
+			func() error {
+				return c.Aliases.changeRecipient(ref.Domain, ref.Name, newEmail, ref.Email, nil)
+			})
+	default:
+		service := c.bccService(ref.Kind)
+		return tx.Do(description,
+			func() error {
+				return service.ChangeRecipient(ref.Domain, ref.Name, newEmail)
+			},
+			func() error {
+				return service.ChangeRecipient(ref.Domain, ref.Name, ref.Email)
+			})
+	}
+}
+
+func (c *Client) bccService(kind string) BccService {
+	if kind == SenderBccReference {
+		return c.OutputBccs
+	}
+	return c.InputBccs
+}
+
+// DeleteAccountCascade deletes the account together with the aliases and BCCs in refs,
+// which are expected to reference the account. If retarget is not empty, the references
+// are changed to point to retarget instead of being removed. If a step fails, the
+// completed steps are rolled back.
+func (c *Client) DeleteAccountCascade(domain, username string, refs []Reference, retarget string) error {
+	if retarget != "" {
+		if err := ValidateEmail(retarget); err != nil {
+			return err
+		}
+	}
+
+	tx := NewTransaction()
+	checker := c.Aliases.newAliasChecker()
+	for _, ref := range refs {
+		var err error
+		if retarget != "" {
+			err = c.retargetReference(tx, checker, ref, retarget)
+		} else {
+			err = c.removeReference(tx, checker, ref)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// The account can't be restored with its password, so it is deleted last.
+	return tx.Do(fmt.Sprintf("delete account %s@%s", username, domain),
+		func() error {
+			return c.Accounts.Delete(domain, username)
+		}, nil)
+}
+
+// RenameAccountPropagate renames the account from 'old' to 'new' and changes the aliases
+// and BCCs in refs, which are expected to reference the old address, to the new address.
+// If forward is true, an alias forwarding mail for the old address to the new one is
+// created. If a step fails, the completed steps are rolled back.
+func (c *Client) RenameAccountPropagate(domain, old, new string, refs []Reference, forward bool) error {
+	oldEmail := fmt.Sprintf("%s@%s", old, domain)
+	newEmail := fmt.Sprintf("%s@%s", new, domain)
+
+	tx := NewTransaction()
+	checker := c.Aliases.newAliasChecker()
+	err := tx.Do(fmt.Sprintf("rename account %s to %s", oldEmail, newEmail),
+		func() error {
+			return c.Accounts.Rename(domain, old, new)
+		},
+		func() error {
+			return c.Accounts.Rename(domain, new, old)
+		})
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		if ref.Kind != AliasReference && strings.EqualFold(ref.Domain, domain) && strings.EqualFold(ref.Name, old) {
+			// The BCCs of the renamed account itself moved with it.
+			ref.Name = new
+		}
+		if err := c.retargetReference(tx, checker, ref, newEmail); err != nil {
+			return err
+		}
+	}
+
+	if forward {
+		return c.createForward(tx, checker, domain, old, newEmail)
+	}
+	return nil
+}
+
+// createForward creates an alias forwarding name@domain to email as a step of the transaction.
+// The alias is checked with the checker.
+func (c *Client) createForward(tx *Transaction, checker *aliasChecker, domain, name, email string) error {
+	return tx.Do(fmt.Sprintf("create alias %s@%s -> %s", name, domain, email),
+		func() error {
+			if err := checker.check(domain, name, email); err != nil {
+				return err
+			}
+			if err := c.Aliases.create(domain, name, email); err != nil {
+				checker.remove(domain, name, email)
+				return err
+			}
+			return nil
+		},
+		func() error {
+			return c.Aliases.Delete(domain, name, email)
+		})
+}
+
+// RenameDomainPropagate renames the domain from 'old' to 'new' and changes the aliases
+// and BCCs in refs, which are expected to reference addresses in the old domain, to the
+// same addresses in the new domain. If forward is not empty, the old domain is created
+// again with aliases forwarding mail for each of the names in forward to the same name
+// in the new domain. If a step fails, the completed steps are rolled back.
+func (c *Client) RenameDomainPropagate(old, new string, refs []Reference, forward []string) error {
+	tx := NewTransaction()
+	checker := c.Aliases.newAliasChecker()
+	err := tx.Do(fmt.Sprintf("rename domain %s to %s", old, new),
+		func() error {
+			return c.Domains.Rename(old, new)
+		},
+		func() error {
+			return c.Domains.Rename(new, old)
+		})
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		if strings.EqualFold(ref.Domain, old) {
+			// The aliases and accounts of the renamed domain moved with it.
+			ref.Domain = new
+		}
+		if err := c.retargetReference(tx, checker, ref, ReplaceDomain(ref.Email, new)); err != nil {
+			return err
+		}
+	}
+
+	if len(forward) == 0 {
+		return nil
+	}
+	err = tx.Do(fmt.Sprintf("create domain %s", old),
+		func() error {
+			return c.Domains.Create(old)
+		},
+		func() error {
+			return c.Domains.Delete(old)
+		})
+	if err != nil {
+		return err
+	}
+	for _, name := range forward {
+		if err := c.createForward(tx, checker, old, name, fmt.Sprintf("%s@%s", name, new)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceDomain returns the email address with its domain part replaced by domain.
+func ReplaceDomain(email, domain string) string {
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		email = email[:i]
+	}
+	return fmt.Sprintf("%s@%s", email, domain)
+}
@@ -0,0 +1,232 @@
+package emailctl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Route kinds, describing what an address resolves to.
+const (
+	// AliasRoute is an alias, which forwards mail to its recipients.
+	AliasRoute = "alias"
+	// MailboxRoute is a local account, which receives the mail.
+	MailboxRoute = "mailbox"
+	// ExternalRoute is an address in a domain which isn't hosted on the server.
+	ExternalRoute = "external"
+	// UnknownRoute is an address in a local domain without an account or alias.
+	UnknownRoute = "unknown"
+	// LoopRoute is an address which was already expanded on the way to it.
+	LoopRoute = "loop"
+)
+
+// Route is a node of the tree of addresses a message is delivered to.
+type Route struct {
+	// Address is the email address.
+	Address string `json:"address"`
+	// Kind is one of the route kinds, or empty if the address wasn't resolved because
+	// the hop leading to it is disabled.
+	Kind string `json:"kind,omitempty"`
+	// Via describes how the address was reached: an alias, a recipient or sender BCC.
+	// It is empty for the recipient of the message.
+	Via string `json:"via,omitempty"`
+	// Enabled reports whether mail is delivered through this hop.
+	Enabled bool `json:"enabled"`
+	// Problem explains why mail isn't delivered through this hop, if it isn't.
+	Problem string `json:"problem,omitempty"`
+	// Routes are the addresses mail is passed on to from this address.
+	Routes []*Route `json:"routes,omitempty"`
+}
+
+// Deliveries returns the mailboxes and external addresses which receive the mail routed
+// to r, in the order they are reached.
+func (r *Route) Deliveries() []string {
+	var result []string
+	seen := make(map[string]bool)
+	var walk func(r *Route)
+	walk = func(r *Route) {
+		if !r.Enabled {
+			return
+		}
+		if r.Kind == MailboxRoute || r.Kind == ExternalRoute {
+			if key := strings.ToLower(r.Address); !seen[key] {
+				seen[key] = true
+				result = append(result, r.Address)
+			}
+		}
+		for _, child := range r.Routes {
+			walk(child)
+		}
+	}
+	walk(r)
+	return result
+}
+
+// Resolve computes where a message to the recipient ends up by expanding aliases
+// recursively and following the recipient BCCs of the reached accounts, taking the
+// enabled state of domains, accounts, aliases and BCCs into account. If sender is
+// not empty, the sender BCC of the sender account is resolved as well and returned
+// as the second route.
+func (c *Client) Resolve(recipient, sender string) ([]*Route, error) {
+	if err := ValidateEmail(recipient); err != nil {
+		return nil, err
+	}
+	r := &resolver{client: c, domains: make(map[string]*Domain)}
+	root, err := r.resolve(recipient, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	routes := []*Route{root}
+
+	if sender != "" {
+		if err := ValidateEmail(sender); err != nil {
+			return nil, err
+		}
+		route, err := r.resolveSenderBcc(sender)
+		if err != nil {
+			return nil, err
+		}
+		if route != nil {
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+// resolver resolves addresses with the client, caching the looked up domains.
+type resolver struct {
+	client  *Client
+	domains map[string]*Domain
+}
+
+// domain returns the local domain with the given name, or nil if it isn't hosted on the server.
+func (r *resolver) domain(name string) (*Domain, error) {
+	key := strings.ToLower(name)
+	if d, ok := r.domains[key]; ok {
+		return d, nil
+	}
+	d, err := r.client.Domains.Get(name)
+	if IsNotFound(err) {
+		d, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.domains[key] = d
+	return d, nil
+}
+
+// resolve resolves the address reached via 'via'. The path holds the addresses expanded
+// on the way to it.
+func (r *resolver) resolve(email, via string, path []string) (*Route, error) {
+	route := &Route{Address: email, Via: via, Enabled: true}
+	for _, p := range path {
+		if strings.EqualFold(p, email) {
+			route.Kind, route.Enabled, route.Problem = LoopRoute, false, "forwarding loop"
+			return route, nil
+		}
+	}
+
+	i := strings.LastIndex(email, "@")
+	name, domainName := email[:i], email[i+1:]
+	domain, err := r.domain(domainName)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil {
+		route.Kind = ExternalRoute
+		return route, nil
+	}
+	if !domain.Enabled {
+		route.Enabled, route.Problem = false, fmt.Sprintf("domain '%s' is disabled", domain.Name)
+	}
+
+	aliases, err := r.client.Aliases.Get(domainName, name)
+	if IsNotFound(err) {
+		aliases, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(aliases) == 0 {
+		return route, r.resolveMailbox(route, domainName, name, path)
+	}
+
+	route.Kind = AliasRoute
+	path = append(path, email)
+	for _, a := range aliases {
+		if strings.EqualFold(a.Email, email) {
+			// An alias to itself keeps a copy in the mailbox.
+			mailbox := &Route{Address: a.Email, Via: AliasRoute, Enabled: a.Enabled}
+			if !a.Enabled {
+				mailbox.Problem = "alias is disabled"
+			} else if err := r.resolveMailbox(mailbox, domainName, name, path); err != nil {
+				return nil, err
+			}
+			route.Routes = append(route.Routes, mailbox)
+			continue
+		}
+		if !a.Enabled {
+			route.Routes = append(route.Routes, &Route{Address: a.Email, Via: AliasRoute, Problem: "alias is disabled"})
+			continue
+		}
+		child, err := r.resolve(a.Email, AliasRoute, path)
+		if err != nil {
+			return nil, err
+		}
+		route.Routes = append(route.Routes, child)
+	}
+	return route, nil
+}
+
+// resolveMailbox resolves the route to the account name@domain and its recipient BCC.
+func (r *resolver) resolveMailbox(route *Route, domain, name string, path []string) error {
+	account, err := r.client.Accounts.Get(domain, name)
+	if IsNotFound(err) {
+		route.Kind, route.Enabled, route.Problem = UnknownRoute, false, "no such account or alias"
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	route.Kind = MailboxRoute
+	if !account.Enabled && route.Enabled {
+		route.Enabled, route.Problem = false, "account is disabled"
+	}
+	if !route.Enabled {
+		return nil
+	}
+
+	bcc, err := getOptionalBcc(r.client.InputBccs, domain, name)
+	if err != nil || bcc == nil {
+		return err
+	}
+	child, err := r.resolveBcc(bcc, RecipientBccReference, append(path, route.Address))
+	if err != nil {
+		return err
+	}
+	route.Routes = append(route.Routes, child)
+	return nil
+}
+
+// resolveSenderBcc resolves the sender BCC of the sender account, returning nil if the
+// sender isn't a local account with a sender BCC.
+func (r *resolver) resolveSenderBcc(sender string) (*Route, error) {
+	i := strings.LastIndex(sender, "@")
+	domain, err := r.domain(sender[i+1:])
+	if err != nil || domain == nil {
+		return nil, err
+	}
+	bcc, err := getOptionalBcc(r.client.OutputBccs, domain.Name, sender[:i])
+	if err != nil || bcc == nil {
+		return nil, err
+	}
+	return r.resolveBcc(bcc, SenderBccReference, []string{sender})
+}
+
+func (r *resolver) resolveBcc(bcc *Bcc, via string, path []string) (*Route, error) {
+	if !bcc.Enabled {
+		return &Route{Address: bcc.Email, Via: via, Problem: fmt.Sprintf("%s is disabled", via)}, nil
+	}
+	return r.resolve(bcc.Email, via, path)
+}
@@ -0,0 +1,190 @@
+package emailctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// newTestClient creates a client for a test server using the handler. The settings are
+// set in viper in addition to the server address and removed when the test ends.
+func newTestClient(t *testing.T, handler http.Handler, settings map[string]string) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := map[string]string{"host": host, "port": port}
+	for k, v := range settings {
+		all[k] = v
+	}
+	for k, v := range all {
+		viper.Set(k, v)
+	}
+	t.Cleanup(func() {
+		for k := range all {
+			viper.Set(k, nil)
+		}
+	})
+
+	c, err := NewClient(ExecutorOption(&Executor{Concurrency: 8}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// testServer serves the JSON responses for API paths relative to /api/v1/ and responds
+// with 404 Not Found to other requests.
+type testServer map[string]interface{}
+
+func (s testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	response, ok := s[strings.TrimPrefix(r.URL.Path, "/api/v1/")]
+	if !ok || r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"Not found"}`)
+		return
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+type testObject map[string]interface{}
+
+func testDomain(name string, enabled bool) testObject {
+	return testObject{"name": name, "enabled": enabled}
+}
+
+func testMailbox(username string, enabled bool) testObject {
+	return testObject{"username": username, "enabled": enabled}
+}
+
+func testRecipient(name, email string, enabled bool) testObject {
+	return testObject{"name": name, "email": email, "enabled": enabled}
+}
+
+func testBcc(email string, enabled bool) testObject {
+	return testObject{"email": email, "enabled": enabled}
+}
+
+// formatRoute returns a line for each node of the route tree, indented by depth.
+func formatRoute(r *Route) string {
+	var lines []string
+	var walk func(r *Route, indent string)
+	walk = func(r *Route, indent string) {
+		line := fmt.Sprintf("%s%s %s via=%s enabled=%t", indent, r.Address, r.Kind, r.Via, r.Enabled)
+		if r.Problem != "" {
+			line += " problem=" + r.Problem
+		}
+		lines = append(lines, line)
+		for _, child := range r.Routes {
+			walk(child, indent+"  ")
+		}
+	}
+	walk(r, "")
+	return strings.Join(lines, "\n")
+}
+
+func TestResolve(t *testing.T) {
+	server := testServer{
+		"domains/x.com":   testDomain("x.com", true),
+		"domains/off.com": testDomain("off.com", false),
+
+		"domains/x.com/accounts/john":                testMailbox("john", true),
+		"domains/x.com/accounts/jane":                testMailbox("jane", false),
+		"domains/x.com/accounts/bob":                 testMailbox("bob", true),
+		"domains/x.com/accounts/carol":               testMailbox("carol", true),
+		"domains/x.com/accounts/mike":                testMailbox("mike", true),
+		"domains/off.com/accounts/dave":              testMailbox("dave", true),
+		"domains/x.com/accounts/bob/bccs/incoming":   testBcc("archive@ext.net", true),
+		"domains/x.com/accounts/carol/bccs/incoming": testBcc("z@ext.net", false),
+		"domains/x.com/accounts/john/bccs/outgoing":  testBcc("bob@x.com", true),
+
+		"domains/x.com/aliases/info": []testObject{
+			testRecipient("info", "john@x.com", true),
+			testRecipient("info", "jane@x.com", true),
+			testRecipient("info", "team@x.com", false),
+			testRecipient("info", "nobody@x.com", true),
+			testRecipient("info", "bob@x.com", true),
+		},
+		"domains/x.com/aliases/mike": []testObject{
+			testRecipient("mike", "mike@x.com", true),
+			testRecipient("mike", "out@ext.net", true),
+		},
+		"domains/x.com/aliases/loop1": []testObject{testRecipient("loop1", "loop2@x.com", true)},
+		"domains/x.com/aliases/loop2": []testObject{testRecipient("loop2", "loop1@x.com", true)},
+	}
+	c := newTestClient(t, server, nil)
+
+	tests := []struct {
+		recipient  string
+		sender     string
+		routes     []string
+		deliveries []string
+	}{
+		{"info@x.com", "", []string{strings.Join([]string{
+			"info@x.com alias via= enabled=true",
+			"  john@x.com mailbox via=alias enabled=true",
+			"  jane@x.com mailbox via=alias enabled=false problem=account is disabled",
+			"  team@x.com  via=alias enabled=false problem=alias is disabled",
+			"  nobody@x.com unknown via=alias enabled=false problem=no such account or alias",
+			"  bob@x.com mailbox via=alias enabled=true",
+			"    archive@ext.net external via=recipient-bcc enabled=true",
+		}, "\n")}, []string{"john@x.com", "bob@x.com", "archive@ext.net"}},
+		{"mike@x.com", "", []string{strings.Join([]string{
+			"mike@x.com alias via= enabled=true",
+			"  mike@x.com mailbox via=alias enabled=true",
+			"  out@ext.net external via=alias enabled=true",
+		}, "\n")}, []string{"mike@x.com", "out@ext.net"}},
+		{"loop1@x.com", "", []string{strings.Join([]string{
+			"loop1@x.com alias via= enabled=true",
+			"  loop2@x.com alias via=alias enabled=true",
+			"    loop1@x.com loop via=alias enabled=false problem=forwarding loop",
+		}, "\n")}, nil},
+		{"dave@off.com", "", []string{
+			"dave@off.com mailbox via= enabled=false problem=domain 'off.com' is disabled",
+		}, nil},
+		{"carol@x.com", "", []string{strings.Join([]string{
+			"carol@x.com mailbox via= enabled=true",
+			"  z@ext.net  via=recipient-bcc enabled=false problem=recipient-bcc is disabled",
+		}, "\n")}, []string{"carol@x.com"}},
+		{"someone@ext.net", "john@x.com", []string{
+			"someone@ext.net external via= enabled=true",
+			"bob@x.com mailbox via=sender-bcc enabled=true\n  archive@ext.net external via=recipient-bcc enabled=true",
+		}, []string{"someone@ext.net"}},
+		{"jane@x.com", "bob@x.com", []string{
+			"jane@x.com mailbox via= enabled=false problem=account is disabled",
+		}, nil},
+	}
+	for _, tt := range tests {
+		routes, err := c.Resolve(tt.recipient, tt.sender)
+		if err != nil {
+			t.Errorf("Resolve(%s, %s) error = %v", tt.recipient, tt.sender, err)
+			continue
+		}
+		var got []string
+		for _, r := range routes {
+			got = append(got, formatRoute(r))
+		}
+		if !reflect.DeepEqual(got, tt.routes) {
+			t.Errorf("Resolve(%s, %s) =\n%s\nwant\n%s", tt.recipient, tt.sender, strings.Join(got, "\n"), strings.Join(tt.routes, "\n"))
+		}
+		if deliveries := routes[0].Deliveries(); !reflect.DeepEqual(deliveries, tt.deliveries) {
+			t.Errorf("Resolve(%s, %s) deliveries = %v, want %v", tt.recipient, tt.sender, deliveries, tt.deliveries)
+		}
+	}
+
+	for _, args := range [][2]string{{"info", ""}, {"info@x.com", "john"}} {
+		if _, err := c.Resolve(args[0], args[1]); err == nil {
+			t.Errorf("Resolve(%s, %s) succeeded, want an error", args[0], args[1])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package emailctl
+
+import (
+	"fmt"
+)
+
+// Search result kinds.
+const (
+	DomainResult    = "domain"
+	AccountResult   = "account"
+	AliasResult     = "alias"
+	RecipientResult = "recipient"
+)
+
+// SearchKinds are the kinds of objects Search can look for.
+var SearchKinds = []string{DomainResult, AccountResult, AliasResult, RecipientResult}
+
+// SearchOptions controls what Search looks for.
+type SearchOptions struct {
+	// Match reports whether a domain name, account username, alias name or alias
+	// recipient matches the search. Account and alias names are matched both by name
+	// and by their full email address.
+	Match func(s string) bool
+	// Kinds limits the search to the given result kinds. All kinds are searched if empty.
+	Kinds []string
+	// Enabled limits the search to enabled or disabled objects, if not nil.
+	Enabled *bool
+}
+
+// SearchResult is a domain, account or alias matching a search.
+type SearchResult struct {
+	// Kind is one of the search result kinds. A RecipientResult is an alias whose
+	// recipient matched.
+	Kind string `json:"kind"`
+	// Domain is the name of the domain of the object.
+	Domain string `json:"domain"`
+	// Address is the domain name or the email address of the account or alias.
+	Address string `json:"address"`
+	// Email is the recipient of an alias.
+	Email string `json:"email,omitempty"`
+	// Enabled reports whether the domain, account or alias is enabled.
+	Enabled bool `json:"enabled"`
+}
+
+// Search loads the domains, accounts and aliases on the server and returns those
+// matching the options.
+func (c *Client) Search(opts *SearchOptions) ([]SearchResult, error) {
+	state, err := c.LoadState(&StateOptions{SkipBccs: true})
+	if err != nil {
+		return nil, err
+	}
+	return state.Search(opts), nil
+}
+
+// Search returns the domains, accounts and aliases of the state matching the options.
+// An alias whose name and recipient both match is returned as an AliasResult and as
+// a RecipientResult.
+func (s *ServerState) Search(opts *SearchOptions) []SearchResult {
+	kinds := make(map[string]bool)
+	for _, k := range opts.Kinds {
+		kinds[k] = true
+	}
+	var results []SearchResult
+	add := func(r SearchResult) {
+		if len(kinds) > 0 && !kinds[r.Kind] {
+			return
+		}
+		if opts.Enabled != nil && *opts.Enabled != r.Enabled {
+			return
+		}
+		results = append(results, r)
+	}
+
+	for _, d := range s.Domains {
+		if opts.Match(d.Name) {
+			add(SearchResult{Kind: DomainResult, Domain: d.Name, Address: d.Name, Enabled: d.Enabled})
+		}
+		for _, a := range d.Accounts {
+			email := fmt.Sprintf("%s@%s", a.Username, d.Name)
+			if opts.Match(a.Username) || opts.Match(email) {
+				add(SearchResult{Kind: AccountResult, Domain: d.Name, Address: email, Enabled: a.Enabled})
+			}
+		}
+		for _, a := range d.Aliases {
+			email := fmt.Sprintf("%s@%s", a.Name, d.Name)
+			if opts.Match(a.Name) || opts.Match(email) {
+				add(SearchResult{Kind: AliasResult, Domain: d.Name, Address: email, Email: a.Email, Enabled: a.Enabled})
+			}
+			if opts.Match(a.Email) {
+				add(SearchResult{Kind: RecipientResult, Domain: d.Name, Address: email, Email: a.Email, Enabled: a.Enabled})
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,263 @@
+package emailctl
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lyubenblagoev/goprsc"
+)
+
+// ServerState is a snapshot of the domains, accounts, aliases and BCCs on the server.
+type ServerState struct {
+	Domains []*DomainState `json:"domains"`
+}
+
+// DomainState holds a domain with its accounts and aliases.
+type DomainState struct {
+	Domain
+	Accounts []*AccountState `json:"accounts"`
+	Aliases  []Alias         `json:"aliases"`
+}
+
+// AccountState holds an account with its BCCs.
+type AccountState struct {
+	Account
+	// SenderBcc is the sender BCC of the account, or nil if it has none.
+	SenderBcc *Bcc `json:"senderBcc,omitempty"`
+	// RecipientBcc is the recipient BCC of the account, or nil if it has none.
+	RecipientBcc *Bcc `json:"recipientBcc,omitempty"`
+}
+
+// StateOptions controls what LoadState loads.
+type StateOptions struct {
+	// Domains limits the state to the given domains. All domains are loaded if empty.
+	Domains []string
+	// SkipBccs skips loading the BCCs, which takes two requests per account.
+	SkipBccs bool
+}
+
+// LoadState loads a snapshot of the server state. Domains, and the accounts within
+// them, are processed concurrently with the client's bulk executor.
+func (c *Client) LoadState(opts *StateOptions) (*ServerState, error) {
+	if opts == nil {
+		opts = &StateOptions{}
+	}
+
+	var domains []Domain
+	if len(opts.Domains) == 0 {
+		var err error
+		if domains, err = c.Domains.List(); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, name := range opts.Domains {
+			d, err := c.Domains.Get(name)
+			if err != nil {
+				return nil, err
+			}
+			domains = append(domains, *d)
+		}
+	}
+
+	state := &ServerState{Domains: make([]*DomainState, len(domains))}
+	names := make([]string, len(domains))
+	for i, d := range domains {
+		state.Domains[i] = &DomainState{Domain: d}
+		names[i] = d.Name
+	}
+	err := c.Bulk.Run("Loading domains", names, func(i int) error {
+		ds := state.Domains[i]
+		accounts, err := c.Accounts.List(ds.Name)
+		if err != nil {
+			return err
+		}
+		for j := range accounts {
+			ds.Accounts = append(ds.Accounts, &AccountState{Account: accounts[j]})
+		}
+		ds.Aliases, err = c.Aliases.List(ds.Name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.SkipBccs {
+		if err := c.loadBccs(state); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+func (c *Client) loadBccs(state *ServerState) error {
+	var domains []string
+	var accounts []*AccountState
+	var emails []string
+	for _, d := range state.Domains {
+		for _, a := range d.Accounts {
+			domains = append(domains, d.Name)
+			accounts = append(accounts, a)
+			emails = append(emails, fmt.Sprintf("%s@%s", a.Username, d.Name))
+		}
+	}
+
+	return c.Bulk.Run("Loading BCCs", emails, func(i int) error {
+		a := accounts[i]
+		var err error
+		if a.SenderBcc, err = getOptionalBcc(c.OutputBccs, domains[i], a.Username); err != nil {
+			return err
+		}
+		a.RecipientBcc, err = getOptionalBcc(c.InputBccs, domains[i], a.Username)
+		return err
+	})
+}
+
+// getOptionalBcc returns the BCC of the account, or nil if the account has no BCC.
+func getOptionalBcc(service BccService, domain, username string) (*Bcc, error) {
+	bcc, err := service.Get(domain, username)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	return bcc, err
+}
+
+// IsNotFound reports whether err is an API error for a missing resource.
+func IsNotFound(err error) bool {
+	e, ok := err.(*goprsc.ErrorResponse)
+	return ok && e.Response != nil && e.Response.StatusCode == http.StatusNotFound
+}
+
+// FindDomain returns the domain with the given name, or nil if there is no such domain.
+func (s *ServerState) FindDomain(name string) *DomainState {
+	for _, d := range s.Domains {
+		if strings.EqualFold(d.Name, name) {
+			return d
+		}
+	}
+	return nil
+}
+
+// FindAccount returns the account with the given email address, or nil if there is no such account.
+func (s *ServerState) FindAccount(email string) (*DomainState, *AccountState) {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return nil, nil
+	}
+	d := s.FindDomain(email[i+1:])
+	if d == nil {
+		return nil, nil
+	}
+	for _, a := range d.Accounts {
+		if strings.EqualFold(a.Username, email[:i]) {
+			return d, a
+		}
+	}
+	return d, nil
+}
+
+// Reference kinds.
+const (
+	AliasReference        = "alias"
+	SenderBccReference    = "sender-bcc"
+	RecipientBccReference = "recipient-bcc"
+)
+
+// Reference is an alias or BCC which forwards or copies mail to an email address.
+type Reference struct {
+	// Kind is one of AliasReference, SenderBccReference and RecipientBccReference.
+	Kind string `json:"kind"`
+	// Domain is the domain of the alias or account holding the reference.
+	Domain string `json:"domain"`
+	// Name is the alias name or account username holding the reference.
+	Name string `json:"name"`
+	// Email is the referenced email address.
+	Email string `json:"email"`
+	// Enabled reports whether the alias or BCC is enabled.
+	Enabled bool `json:"enabled"`
+}
+
+// Source returns the email address of the alias or account holding the reference.
+func (r *Reference) Source() string {
+	return fmt.Sprintf("%s@%s", r.Name, r.Domain)
+}
+
+func (r *Reference) String() string {
+	return fmt.Sprintf("%s %s -> %s", r.Kind, r.Source(), r.Email)
+}
+
+// DisabledSuffix returns the suffix which marks disabled objects in listings and plans.
+func DisabledSuffix(enabled bool) string {
+	if enabled {
+		return ""
+	}
+	return " (disabled)"
+}
+
+// References returns all aliases and BCCs referencing addresses for which match returns true.
+func (s *ServerState) References(match func(email string) bool) []Reference {
+	var refs []Reference
+	for _, d := range s.Domains {
+		for _, a := range d.Aliases {
+			if match(a.Email) {
+				refs = append(refs, Reference{Kind: AliasReference, Domain: d.Name, Name: a.Name, Email: a.Email, Enabled: a.Enabled})
+			}
+		}
+		for _, a := range d.Accounts {
+			if a.SenderBcc != nil && match(a.SenderBcc.Email) {
+				refs = append(refs, Reference{Kind: SenderBccReference, Domain: d.Name, Name: a.Username, Email: a.SenderBcc.Email, Enabled: a.SenderBcc.Enabled})
+			}
+			if a.RecipientBcc != nil && match(a.RecipientBcc.Email) {
+				refs = append(refs, Reference{Kind: RecipientBccReference, Domain: d.Name, Name: a.Username, Email: a.RecipientBcc.Email, Enabled: a.RecipientBcc.Enabled})
+			}
+		}
+	}
+	return refs
+}
+
+// ReferencesTo returns all aliases and BCCs referencing the email address.
+func (s *ServerState) ReferencesTo(email string) []Reference {
+	return s.References(func(e string) bool {
+		return strings.EqualFold(e, email)
+	})
+}
+
+// ReferencesToDomain returns all aliases and BCCs referencing addresses in the domain.
+func (s *ServerState) ReferencesToDomain(domain string) []Reference {
+	suffix := "@" + strings.ToLower(domain)
+	return s.References(func(e string) bool {
+		return strings.HasSuffix(strings.ToLower(e), suffix)
+	})
+}
+
+// Addresses returns the distinct names of the accounts and aliases of the domain.
+func (d *DomainState) Addresses() []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			names = append(names, name)
+		}
+	}
+	for _, a := range d.Accounts {
+		add(a.Username)
+	}
+	for _, a := range d.Aliases {
+		add(a.Name)
+	}
+	return names
+}
+
+// FindReferences loads the server state and returns all aliases and BCCs referencing
+// the email address.
+func (c *Client) FindReferences(email string) ([]Reference, error) {
+	if err := ValidateEmail(email); err != nil {
+		return nil, err
+	}
+	state, err := c.LoadState(nil)
+	if err != nil {
+		return nil, err
+	}
+	return state.ReferencesTo(email), nil
+}
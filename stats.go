@@ -0,0 +1,188 @@
+package emailctl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats are the counts of accounts, aliases and BCCs in one or more domains.
+type Stats struct {
+	// Domains and DisabledDomains are only set in the server-wide total.
+	Domains         int `json:"domains,omitempty"`
+	DisabledDomains int `json:"disabledDomains,omitempty"`
+
+	Accounts         int `json:"accounts"`
+	EnabledAccounts  int `json:"enabledAccounts"`
+	DisabledAccounts int `json:"disabledAccounts"`
+	// Aliases is the number of distinct alias addresses.
+	Aliases int `json:"aliases"`
+	// AliasRecipients is the number of distinct alias recipients, of which
+	// LocalRecipients are in domains on the server and ExternalRecipients are not.
+	AliasRecipients    int `json:"aliasRecipients"`
+	LocalRecipients    int `json:"localRecipients"`
+	ExternalRecipients int `json:"externalRecipients"`
+	SenderBccs         int `json:"senderBccs"`
+	RecipientBccs      int `json:"recipientBccs"`
+	// Months are the numbers of domains, accounts and aliases created per month, in
+	// chronological order. Months without any are left out.
+	Months []MonthStats `json:"months"`
+}
+
+// MonthStats are the numbers of domains, accounts and aliases created in a month.
+type MonthStats struct {
+	// Month is the year and month in the format 2006-01.
+	Month    string `json:"month"`
+	Domains  int    `json:"domains"`
+	Accounts int    `json:"accounts"`
+	// Aliases is the number of created alias recipients.
+	Aliases int `json:"aliases"`
+}
+
+// DomainStats are the stats of a domain.
+type DomainStats struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Stats
+}
+
+// ServerStats are the stats of each domain and the server-wide total.
+type ServerStats struct {
+	Domains []*DomainStats `json:"domains"`
+	Total   *Stats         `json:"total"`
+}
+
+// LoadStats loads the state of the domains, or of all domains if none are given, and
+// returns their stats. Recipients are local if they are in any domain on the server.
+func (c *Client) LoadStats(domains []string) (*ServerStats, error) {
+	state, err := c.LoadState(&StateOptions{Domains: domains})
+	if err != nil {
+		return nil, err
+	}
+	var local []string
+	if len(domains) > 0 {
+		all, err := c.Domains.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range all {
+			local = append(local, d.Name)
+		}
+	}
+	return state.Stats(local), nil
+}
+
+// Stats counts the accounts, aliases and BCCs of each domain in the state and of all
+// of them together. Alias recipients in the local domains are counted as local; if
+// local is nil, the domains of the state are the local domains. The state must include
+// the BCCs for them to be counted.
+func (s *ServerState) Stats(local []string) *ServerStats {
+	if local == nil {
+		for _, d := range s.Domains {
+			local = append(local, d.Name)
+		}
+	}
+	isLocal := make(map[string]bool)
+	for _, name := range local {
+		isLocal[strings.ToLower(name)] = true
+	}
+
+	stats := &ServerStats{}
+	total := &statsCounter{local: isLocal}
+	for _, d := range s.Domains {
+		c := &statsCounter{local: isLocal}
+		c.addDomain(d)
+		total.addDomain(d)
+		stats.Domains = append(stats.Domains, &DomainStats{Name: d.Name, Enabled: d.Enabled, Stats: *c.stats()})
+	}
+	stats.Total = total.stats()
+	stats.Total.Domains = len(s.Domains)
+	for _, d := range s.Domains {
+		if !d.Enabled {
+			stats.Total.DisabledDomains++
+		}
+	}
+	return stats
+}
+
+// statsCounter collects the stats of one or more domains.
+type statsCounter struct {
+	local      map[string]bool
+	counts     Stats
+	aliases    map[string]bool
+	recipients map[string]bool
+	months     map[string]*MonthStats
+}
+
+func (c *statsCounter) addDomain(d *DomainState) {
+	if c.aliases == nil {
+		c.aliases = make(map[string]bool)
+		c.recipients = make(map[string]bool)
+		c.months = make(map[string]*MonthStats)
+	}
+	if m := c.month(d.Created.Time); m != nil {
+		m.Domains++
+	}
+
+	for _, a := range d.Accounts {
+		c.counts.Accounts++
+		if a.Enabled {
+			c.counts.EnabledAccounts++
+		} else {
+			c.counts.DisabledAccounts++
+		}
+		if a.SenderBcc != nil {
+			c.counts.SenderBccs++
+		}
+		if a.RecipientBcc != nil {
+			c.counts.RecipientBccs++
+		}
+		if m := c.month(a.Created.Time); m != nil {
+			m.Accounts++
+		}
+	}
+
+	for _, a := range d.Aliases {
+		c.aliases[strings.ToLower(fmt.Sprintf("%s@%s", a.Name, d.Name))] = true
+		if email := strings.ToLower(a.Email); !c.recipients[email] {
+			c.recipients[email] = true
+			if i := strings.LastIndex(email, "@"); i >= 0 && c.local[email[i+1:]] {
+				c.counts.LocalRecipients++
+			} else {
+				c.counts.ExternalRecipients++
+			}
+		}
+		if m := c.month(a.Created.Time); m != nil {
+			m.Aliases++
+		}
+	}
+}
+
+// month returns the counts of the month of t, or nil if t is not set.
+func (c *statsCounter) month(t time.Time) *MonthStats {
+	if t.IsZero() {
+		return nil
+	}
+	key := t.Format("2006-01")
+	m, ok := c.months[key]
+	if !ok {
+		m = &MonthStats{Month: key}
+		c.months[key] = m
+	}
+	return m
+}
+
+func (c *statsCounter) stats() *Stats {
+	stats := c.counts
+	stats.Aliases = len(c.aliases)
+	stats.AliasRecipients = len(c.recipients)
+	stats.Months = []MonthStats{}
+	for _, m := range c.months {
+		stats.Months = append(stats.Months, *m)
+	}
+	sort.Slice(stats.Months, func(i, j int) bool {
+		return stats.Months[i].Month < stats.Months[j].Month
+	})
+	return &stats
+}
@@ -0,0 +1,101 @@
+package emailctl
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// Transaction runs the steps of a composite operation and reverts the completed
+// steps, in reverse order, if one of them fails. It is safe for concurrent use.
+type Transaction struct {
+	mu        sync.Mutex
+	completed []transactionStep
+}
+
+type transactionStep struct {
+	description string
+	compensate  func() error
+}
+
+// NewTransaction creates an empty Transaction.
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// Do runs a step of the transaction. The compensate function must revert the effect
+// of 'do' and may be nil if there is nothing to revert. If 'do' fails, all previously
+// completed steps are rolled back and a *TransactionError is returned.
+func (t *Transaction) Do(description string, do, compensate func() error) error {
+	if err := do(); err != nil {
+		return t.Rollback(description, err)
+	}
+	t.Completed(description, compensate)
+	return nil
+}
+
+// Completed records a step that was run outside of Do, so it is rolled back if the
+// transaction fails.
+func (t *Transaction) Completed(description string, compensate func() error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.completed = append(t.completed, transactionStep{description: description, compensate: compensate})
+}
+
+// Rollback reverts all completed steps in reverse order and returns a *TransactionError
+// reporting the failed step, its error and the outcome of the rollback.
+func (t *Transaction) Rollback(description string, cause error) error {
+	t.mu.Lock()
+	steps := t.completed
+	t.completed = nil
+	t.mu.Unlock()
+
+	e := &TransactionError{Step: description, Err: cause}
+	for i := len(steps) - 1; i >= 0; i-- {
+		s := steps[i]
+		if s.compensate == nil {
+			continue
+		}
+		if err := s.compensate(); err != nil {
+			e.RollbackFailures = append(e.RollbackFailures, fmt.Sprintf("%s: %v", s.description, err))
+			continue
+		}
+		e.RolledBack = append(e.RolledBack, s.description)
+	}
+	return e
+}
+
+// TransactionError is returned when a step of a Transaction fails.
+type TransactionError struct {
+	// Step describes the failed step.
+	Step string
+	// Err is the error of the failed step.
+	Err error
+	// RolledBack describes the steps which were rolled back.
+	RolledBack []string
+	// RollbackFailures describes the steps which could not be rolled back and why.
+	RollbackFailures []string
+}
+
+func (e *TransactionError) Error() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("failed to %s: %v", e.Step, e.Err))
+	if len(e.RolledBack) > 0 {
+		buffer.WriteString("\nRolled back:")
+		for _, s := range e.RolledBack {
+			buffer.WriteString("\n  " + s)
+		}
+	}
+	if len(e.RollbackFailures) > 0 {
+		buffer.WriteString("\nFailed to roll back:")
+		for _, s := range e.RollbackFailures {
+			buffer.WriteString("\n  " + s)
+		}
+	}
+	return buffer.String()
+}
+
+// Unwrap returns the error of the failed step.
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,85 @@
+package emailctl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransactionRollback(t *testing.T) {
+	var reverted []string
+	compensate := func(step string, err error) func() error {
+		return func() error {
+			reverted = append(reverted, step)
+			return err
+		}
+	}
+	succeed := func() error { return nil }
+	cause := errors.New("server error")
+
+	tx := NewTransaction()
+	steps := []struct {
+		description string
+		compensate  func() error
+	}{
+		{"create domain", compensate("create domain", nil)},
+		{"create account", compensate("create account", errors.New("not found"))},
+		{"disable account", nil},
+		{"create alias", compensate("create alias", nil)},
+	}
+	for _, s := range steps {
+		if err := tx.Do(s.description, succeed, s.compensate); err != nil {
+			t.Fatalf("Do(%s) error = %v", s.description, err)
+		}
+	}
+	tx.Completed("create bcc", compensate("create bcc", nil))
+
+	ran := false
+	err := tx.Do("rename alias", func() error { return cause }, func() error {
+		ran = true
+		return nil
+	})
+	if ran {
+		t.Error("the failed step was rolled back")
+	}
+	if want := []string{"create bcc", "create alias", "create account", "create domain"}; !reflect.DeepEqual(reverted, want) {
+		t.Errorf("steps reverted in order %v, want %v", reverted, want)
+	}
+
+	txErr, ok := err.(*TransactionError)
+	if !ok {
+		t.Fatalf("Do() error = %v, want a *TransactionError", err)
+	}
+	if txErr.Step != "rename alias" || txErr.Err != cause {
+		t.Errorf("failed step = %s: %v, want rename alias: %v", txErr.Step, txErr.Err, cause)
+	}
+	if want := []string{"create bcc", "create alias", "create domain"}; !reflect.DeepEqual(txErr.RolledBack, want) {
+		t.Errorf("RolledBack = %v, want %v", txErr.RolledBack, want)
+	}
+	if want := []string{"create account: not found"}; !reflect.DeepEqual(txErr.RollbackFailures, want) {
+		t.Errorf("RollbackFailures = %v, want %v", txErr.RollbackFailures, want)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false, want true")
+	}
+	for _, s := range []string{"failed to rename alias: server error", "Rolled back:\n  create bcc", "Failed to roll back:\n  create account: not found"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Error() = %q, want it to contain %q", err.Error(), s)
+		}
+	}
+
+	// The steps are only rolled back once.
+	reverted = nil
+	err = tx.Rollback("save", cause)
+	if len(reverted) > 0 || len(err.(*TransactionError).RolledBack) > 0 {
+		t.Errorf("second rollback reverted %v", reverted)
+	}
+}
+
+func TestTransactionRollbackNothingCompleted(t *testing.T) {
+	err := NewTransaction().Rollback("create domain", errors.New("exists"))
+	if got, want := err.Error(), "failed to create domain: exists"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
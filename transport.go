@@ -0,0 +1,187 @@
+package emailctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/lyubenblagoev/goprsc"
+)
+
+// redactedFields are the request body fields whose values are never printed or recorded.
+var redactedFields = []string{"password", "confirmPassword", "refreshToken", "token"}
+
+// isMutating reports whether the request changes state on the server. Authentication
+// requests are not considered mutating.
+func isMutating(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return !strings.Contains(req.URL.Path, "/auth/")
+}
+
+// readBody reads the request body and replaces it with an in-memory copy, so the
+// request can still be sent.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// redactBody returns the JSON request body with the values of secret fields replaced.
+func redactBody(body []byte) json.RawMessage {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return json.RawMessage(body)
+	}
+	for _, f := range redactedFields {
+		if _, ok := fields[f]; ok {
+			fields[f] = "REDACTED"
+		}
+	}
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return json.RawMessage(body)
+	}
+	return redacted
+}
+
+// dryRunTransport prints mutating requests instead of sending them. Read requests
+// are passed through to the next transport.
+type dryRunTransport struct {
+	next http.RoundTripper
+	out  io.Writer
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isMutating(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if redacted := redactBody(body); len(redacted) > 0 {
+		fmt.Fprintf(t.out, "DRY RUN: %s %s %s\n", req.Method, req.URL, redacted)
+	} else {
+		fmt.Fprintf(t.out, "DRY RUN: %s %s\n", req.Method, req.URL)
+	}
+
+	return &http.Response{
+		Status:     "204 No Content",
+		StatusCode: http.StatusNoContent,
+		Proto:      req.Proto,
+		ProtoMajor: req.ProtoMajor,
+		ProtoMinor: req.ProtoMinor,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+		Request:    req,
+	}, nil
+}
+
+// authTransport authenticates the API requests and refreshes the tokens when the
+// authentication token is rejected. The tokens are guarded by a mutex, so the items of
+// bulk operations can be sent concurrently, and a rejected token is refreshed only once,
+// by the first request which gets the rejection; the others are sent again with the
+// new token.
+type authTransport struct {
+	next   http.RoundTripper
+	client *goprsc.Client
+
+	mu           sync.Mutex
+	authToken    string
+	refreshToken string
+}
+
+// tokens returns the current authentication and refresh tokens.
+func (t *authTransport) tokens() (string, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.authToken, t.refreshToken
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	authToken, _ := t.tokens()
+	if authToken == "" || strings.Contains(req.URL.Path, "/auth/") {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(t.authenticate(req, body, authToken))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	newToken, err := t.refresh(authToken)
+	if err != nil || newToken == "" {
+		return resp, nil
+	}
+	resp.Body.Close()
+	return t.next.RoundTrip(t.authenticate(req, body, newToken))
+}
+
+// authenticate returns a copy of the request with the given body and authentication token.
+func (t *authTransport) authenticate(req *http.Request, body []byte, authToken string) *http.Request {
+	r := req.Clone(req.Context())
+	if body != nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	r.Header.Set("Authorization", "Bearer "+authToken)
+	return r
+}
+
+// refresh refreshes the tokens if the rejected authentication token is still the current
+// one and returns the current authentication token.
+func (t *authTransport) refresh(rejected string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.authToken != rejected {
+		return t.authToken, nil
+	}
+	if t.refreshToken == "" {
+		return "", nil
+	}
+
+	req, err := t.client.NewRequest(http.MethodPost, "auth/refresh-token", &goprsc.RefreshTokenRequest{
+		Login:        t.client.Login,
+		RefreshToken: t.refreshToken,
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to refresh the authentication token: %s", resp.Status)
+	}
+	var auth goprsc.AuthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return "", err
+	}
+	t.authToken, t.refreshToken = auth.AuthToken, auth.RefreshToken
+	return t.authToken, nil
+}
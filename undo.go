@@ -0,0 +1,344 @@
+package emailctl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lyubenblagoev/goprsc"
+)
+
+// undoOperation is the operation of the journal records marking reverted changes.
+const undoOperation = "undo"
+
+// UndoResult describes the outcome of reverting the change recorded by an audit record.
+type UndoResult struct {
+	// Record is the reverted audit record.
+	Record AuditRecord
+	// Note holds additional information about how the change was reverted, if any, or
+	// why it was skipped.
+	Note string
+	// Skipped reports that the change can't be reverted, like a password change. Skipped
+	// changes are not marked as undone in the journal.
+	Skipped bool
+	// Err is the reason the change could not be reverted, if it could not.
+	Err error
+}
+
+// notUndoableError is returned for changes which can't be reverted.
+type notUndoableError struct {
+	reason string
+}
+
+func (e *notUndoableError) Error() string {
+	return e.reason
+}
+
+// FindUndoable returns the journal records whose changes are reverted when undoing 'id',
+// which is the ID of a single record or of a session (a single emailctl invocation).
+// When id is empty, the records of the most recent session with revertible changes are
+// returned. The records are returned in the order they should be reverted, newest first.
+func (c *Client) FindUndoable(id string) ([]AuditRecord, error) {
+	if c.audit == nil {
+		return nil, errors.New("undo requires the audit journal, which is disabled")
+	}
+	records, err := c.audit.journal.Read(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	undone := make(map[string]bool)
+	undoSessions := make(map[string]bool)
+	for _, r := range records {
+		if r.Operation == undoOperation {
+			undone[r.Undoes] = true
+			undoSessions[r.Session] = true
+		}
+	}
+	revertible := func(r *AuditRecord) bool {
+		return r.Operation != undoOperation && r.Succeeded() && !undone[r.ID] &&
+			(strings.HasPrefix(r.Operation, "create ") || len(r.Before) > 0)
+	}
+
+	session := id
+	if id == "" {
+		// Sessions which only changed passwords have nothing which can be reverted.
+		for i := len(records) - 1; i >= 0; i-- {
+			if revertible(&records[i]) && !records[i].changesPassword() && !undoSessions[records[i].Session] {
+				session = records[i].Session
+				break
+			}
+		}
+		if session == "" {
+			return nil, errors.New("there are no changes to undo")
+		}
+	}
+
+	var found bool
+	var result []AuditRecord
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.ID != session && r.Session != session {
+			continue
+		}
+		found = true
+		if revertible(&r) {
+			result = append(result, r)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no change or session with ID '%s' in the audit journal", id)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("the changes recorded as '%s' were already undone or can't be undone", id)
+	}
+	return result, nil
+}
+
+// changesPassword reports whether the record is an update of the password of an account.
+func (r *AuditRecord) changesPassword() bool {
+	if strings.HasPrefix(r.Operation, "create ") || parseResource("", r.Resource, nil).kind != accountResource {
+		return false
+	}
+	var args undoArguments
+	return json.Unmarshal(r.Arguments, &args) == nil && args.Password != ""
+}
+
+// Undo reverts the changes recorded by the given audit records in order and records
+// each reverted change in the audit journal. Deleted accounts are recreated with the
+// password returned by 'password' for their address.
+func (c *Client) Undo(records []AuditRecord, password func(email string) (string, error)) []UndoResult {
+	results := make([]UndoResult, len(records))
+	for i, r := range records {
+		results[i].Record = r
+		if server := fmt.Sprintf("%s:%s", c.client.Host, c.client.Port); r.Server != server {
+			results[i].Err = fmt.Errorf("the change was made on server %s, not %s", r.Server, server)
+			continue
+		}
+
+		results[i].Note, results[i].Err = c.undo(&r, password)
+		if e, ok := results[i].Err.(*notUndoableError); ok {
+			results[i].Note, results[i].Skipped, results[i].Err = e.reason, true, nil
+			continue
+		}
+		if results[i].Err != nil || c.dryRun {
+			continue
+		}
+
+		marker := &AuditRecord{
+			ID:        randomID(6),
+			Session:   c.audit.session,
+			Time:      time.Now(),
+			User:      c.audit.user,
+			Login:     c.client.Login,
+			Server:    r.Server,
+			Command:   c.audit.command,
+			Operation: undoOperation,
+			Resource:  r.Resource,
+			Target:    r.Target,
+			Undoes:    r.ID,
+		}
+		if err := c.audit.journal.Append(marker); err != nil {
+			results[i].Err = fmt.Errorf("the change was undone, but could not be recorded in the audit journal: %v", err)
+		}
+	}
+	return results
+}
+
+// undoArguments holds the fields of the request bodies of mutating requests.
+type undoArguments struct {
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Enabled  bool   `json:"enabled"`
+}
+
+func (c *Client) undo(r *AuditRecord, password func(email string) (string, error)) (string, error) {
+	res := parseResource("", r.Resource, nil)
+	verb := strings.SplitN(r.Operation, " ", 2)[0]
+	var args undoArguments
+	if len(r.Arguments) > 0 {
+		if err := json.Unmarshal(r.Arguments, &args); err != nil {
+			return "", err
+		}
+	}
+	if verb != "create" && len(r.Before) == 0 {
+		return "", errors.New("the state before the change was not recorded")
+	}
+
+	switch res.kind {
+	case domainResource:
+		return c.undoDomain(verb, res, &args, r.Before)
+	case accountResource:
+		return c.undoAccount(verb, res, &args, r.Before, password)
+	case aliasResource:
+		return c.undoAlias(verb, res, &args, r.Before)
+	case senderBccResource:
+		return c.undoBcc(c.OutputBccs, verb, res, &args, r.Before)
+	case recipientBccResource:
+		return c.undoBcc(c.InputBccs, verb, res, &args, r.Before)
+	}
+	return "", fmt.Errorf("unsupported operation '%s'", r.Operation)
+}
+
+func (c *Client) undoDomain(verb string, res resource, args *undoArguments, beforeImage json.RawMessage) (string, error) {
+	if verb == "create" {
+		return "", c.Domains.Delete(res.domain)
+	}
+
+	var before goprsc.Domain
+	if err := json.Unmarshal(beforeImage, &before); err != nil {
+		return "", err
+	}
+	if verb == "delete" {
+		if err := c.Domains.Create(before.Name); err != nil {
+			return "", err
+		}
+		note := "the accounts and aliases of the domain were not restored"
+		if !before.Enabled {
+			return note, c.Domains.Disable(before.Name)
+		}
+		return note, nil
+	}
+
+	current := res.domain
+	if args.Name != "" && args.Name != current {
+		current = args.Name
+		if err := c.Domains.Rename(current, before.Name); err != nil {
+			return "", err
+		}
+	}
+	if args.Enabled != before.Enabled {
+		return "", setEnabled(c.Domains.Enable, c.Domains.Disable, before.Enabled, before.Name)
+	}
+	return "", nil
+}
+
+func (c *Client) undoAccount(verb string, res resource, args *undoArguments, beforeImage json.RawMessage, password func(email string) (string, error)) (string, error) {
+	if verb == "create" {
+		return "", c.Accounts.Delete(res.domain, res.account)
+	}
+	if args.Password != "" {
+		return "", &notUndoableError{"the previous password can't be restored"}
+	}
+
+	var before goprsc.Account
+	if err := json.Unmarshal(beforeImage, &before); err != nil {
+		return "", err
+	}
+	if verb == "delete" {
+		newPassword, err := password(fmt.Sprintf("%s@%s", before.Username, res.domain))
+		if err != nil {
+			return "", err
+		}
+		if err := c.Accounts.Create(res.domain, before.Username, newPassword); err != nil {
+			return "", err
+		}
+		note := "the account was recreated with a new password"
+		if !before.Enabled {
+			return note, c.Accounts.Disable(res.domain, before.Username)
+		}
+		return note, nil
+	}
+
+	current := res.account
+	if args.Username != "" && args.Username != current {
+		current = args.Username
+		if err := c.Accounts.Rename(res.domain, current, before.Username); err != nil {
+			return "", err
+		}
+	}
+	if args.Enabled != before.Enabled {
+		err := setEnabled(
+			func(username string) error { return c.Accounts.Enable(res.domain, username) },
+			func(username string) error { return c.Accounts.Disable(res.domain, username) },
+			before.Enabled, before.Username)
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+func (c *Client) undoAlias(verb string, res resource, args *undoArguments, beforeImage json.RawMessage) (string, error) {
+	if verb == "create" {
+		return "", c.Aliases.Delete(res.domain, res.alias, res.email)
+	}
+
+	var before goprsc.Alias
+	if err := json.Unmarshal(beforeImage, &before); err != nil {
+		return "", err
+	}
+	if verb == "delete" {
+		if err := c.Aliases.create(res.domain, before.Name, before.Email); err != nil {
+			return "", err
+		}
+		if !before.Enabled {
+			return "", c.Aliases.Disable(res.domain, before.Name, before.Email)
+		}
+		return "", nil
+	}
+
+	name, email := res.alias, res.email
+	if args.Email != "" {
+		email = args.Email
+	}
+	if args.Name != "" && args.Name != name {
+		name = args.Name
+		if err := c.Aliases.Rename(res.domain, name, email, before.Name); err != nil {
+			return "", err
+		}
+	}
+	if email != before.Email {
+		if err := c.Aliases.ChangeRecipient(res.domain, before.Name, email, before.Email); err != nil {
+			return "", err
+		}
+	}
+	if args.Enabled != before.Enabled {
+		return "", c.Aliases.setEnabled(res.domain, before.Name, before.Email, before.Enabled)
+	}
+	return "", nil
+}
+
+func (c *Client) undoBcc(service BccService, verb string, res resource, args *undoArguments, beforeImage json.RawMessage) (string, error) {
+	if verb == "create" {
+		return "", service.Delete(res.domain, res.account)
+	}
+
+	var before goprsc.Bcc
+	if err := json.Unmarshal(beforeImage, &before); err != nil {
+		return "", err
+	}
+	if verb == "delete" {
+		if err := service.Create(res.domain, res.account, before.Email); err != nil {
+			return "", err
+		}
+		if !before.Enabled {
+			return "", service.Disable(res.domain, res.account)
+		}
+		return "", nil
+	}
+
+	if args.Email != "" && args.Email != before.Email {
+		if err := service.ChangeRecipient(res.domain, res.account, before.Email); err != nil {
+			return "", err
+		}
+	}
+	if args.Enabled != before.Enabled {
+		return "", setEnabled(
+			func(username string) error { return service.Enable(res.domain, username) },
+			func(username string) error { return service.Disable(res.domain, username) },
+			before.Enabled, res.account)
+	}
+	return "", nil
+}
+
+func setEnabled(enable, disable func(string) error, enabled bool, name string) error {
+	if enabled {
+		return enable(name)
+	}
+	return disable(name)
+}
@@ -0,0 +1,68 @@
+package emailctl
+
+// defaultWordlist is the built-in word list used for diceware style passwords
+// when no custom word list is configured.
+var defaultWordlist = []string{
+	"able", "acid", "acorn", "actor", "adapt", "admit", "adopt", "adult", "agent", "agile",
+	"aisle", "alarm", "album", "alert", "algae", "alley", "alloy", "alpha", "amber", "amuse",
+	"angle", "ankle", "apple", "april", "apron", "arena", "argue", "armor", "aroma", "arrow",
+	"ashes", "aspen", "atlas", "attic", "audio", "aunt", "autumn", "avoid", "awake", "award",
+	"axis", "bacon", "badge", "bagel", "baker", "balmy", "bamboo", "banjo", "barge", "baron",
+	"basil", "basin", "batch", "beach", "beard", "beast", "begin", "bench", "berry", "bike",
+	"birch", "bison", "blade", "blank", "blaze", "blend", "bliss", "block", "bloom", "blues",
+	"blunt", "board", "boast", "bonus", "boost", "booth", "boots", "bored", "brain", "brake",
+	"brave", "bread", "brick", "bride", "brief", "brink", "brisk", "broad", "broom", "brush",
+	"bucket", "buddy", "bugle", "build", "bulb", "bunch", "bunny", "cabin", "cable", "cactus",
+	"camel", "candy", "canoe", "canyon", "cargo", "carol", "carrot", "castle", "cedar", "chalk",
+	"charm", "chase", "cheek", "cheer", "chess", "chief", "chili", "chirp", "choir", "chunk",
+	"cider", "cinema", "civic", "claim", "clamp", "clash", "clerk", "cliff", "climb", "cloak",
+	"clock", "cloud", "clover", "coach", "coast", "cobra", "cocoa", "comet", "coral", "cotton",
+	"couch", "cousin", "crane", "crate", "crisp", "crown", "crumb", "crust", "cubic", "curve",
+	"cycle", "daily", "daisy", "dance", "dandy", "delta", "denim", "depot", "desert", "diary",
+	"diner", "disco", "ditch", "diver", "dizzy", "dodge", "dolphin", "donut", "dough", "dozen",
+	"draft", "dragon", "drama", "dream", "drift", "drill", "drum", "duck", "dune", "eagle",
+	"early", "earth", "easel", "ebony", "echo", "elbow", "elder", "ember", "empty", "enjoy",
+	"entry", "epoch", "equal", "erupt", "essay", "ethic", "event", "exact", "exile", "extra",
+	"fable", "fairy", "faith", "fancy", "fever", "fiber", "field", "fiery", "final", "flame",
+	"flash", "fleet", "flint", "float", "flock", "flora", "flour", "fluid", "flute", "focus",
+	"foggy", "forge", "forty", "fossil", "frame", "fresh", "frost", "fruit", "fudge", "funny",
+	"fuzzy", "gadget", "galaxy", "gamma", "garden", "gecko", "genie", "giant", "ginger", "glade",
+	"glass", "glide", "globe", "glove", "glow", "goat", "golden", "goose", "gorge", "grace",
+	"grain", "grape", "graph", "grass", "gravy", "great", "green", "grill", "group", "grove",
+	"guard", "guest", "guide", "guitar", "habit", "happy", "harbor", "harp", "hatch", "haven",
+	"hazel", "heart", "hedge", "helix", "hello", "herbs", "hero", "hinge", "hobby", "honey",
+	"hook", "hopper", "horse", "hotel", "humble", "hummus", "husky", "igloo", "image", "inbox",
+	"index", "inlet", "input", "iron", "island", "ivory", "jacket", "jaguar", "jelly", "jewel",
+	"jockey", "joint", "jolly", "judge", "juice", "jumbo", "jungle", "kayak", "kettle", "kiosk",
+	"kitten", "knack", "knee", "knife", "koala", "label", "ladder", "lagoon", "lake", "lamp",
+	"lance", "laser", "latch", "lava", "lemon", "lever", "light", "lilac", "limit", "linen",
+	"lion", "liver", "llama", "lobby", "lodge", "lotus", "lucky", "lunar", "lunch", "lyric",
+	"magic", "magnet", "mango", "manor", "maple", "marble", "march", "marsh", "mason", "meadow",
+	"medal", "melon", "mercy", "merit", "metal", "meteor", "mild", "mimic", "minor", "mint",
+	"mirror", "mocha", "model", "mole", "money", "month", "moose", "mossy", "motel", "motor",
+	"mount", "mouse", "movie", "muffin", "mural", "music", "naval", "nectar", "needle", "nerve",
+	"nest", "ninja", "noble", "noodle", "north", "notch", "novel", "nudge", "nylon", "oasis",
+	"ocean", "olive", "omega", "onion", "opera", "orbit", "orchid", "organ", "otter", "outer",
+	"oxide", "oyster", "paddle", "palace", "panda", "panel", "panic", "paper", "parade", "parka",
+	"pasta", "patch", "peach", "pearl", "pecan", "pedal", "pepper", "piano", "pickle", "pilot",
+	"pinch", "pixel", "pizza", "plaid", "plain", "planet", "plaza", "plume", "plush", "poem",
+	"polar", "pony", "poppy", "porch", "pouch", "power", "prism", "prize", "proud", "pulse",
+	"punch", "puppy", "quail", "quake", "quartz", "queen", "quest", "quick", "quiet", "quilt",
+	"quote", "rabbit", "radar", "radio", "rainy", "ranch", "raven", "razor", "rebel", "recipe",
+	"relay", "remix", "rhino", "ribbon", "ridge", "rifle", "river", "roast", "robin", "robot",
+	"rocket", "rodeo", "rogue", "roster", "royal", "ruby", "rugby", "ruler", "rustic", "saddle",
+	"safari", "salad", "salmon", "salsa", "sandy", "satin", "sauce", "scarf", "scout", "sedan",
+	"seed", "shadow", "shark", "shelf", "shell", "shine", "shrub", "sigma", "silk", "silver",
+	"siren", "skate", "sketch", "slate", "sleet", "slope", "smile", "smoke", "snack", "snail",
+	"solar", "sonic", "spark", "spice", "spider", "spine", "spoon", "spray", "sprout", "squid",
+	"stable", "stair", "stamp", "steam", "steel", "stone", "storm", "stove", "straw", "stripe",
+	"sugar", "sunny", "surf", "swamp", "swift", "syrup", "table", "taco", "talon", "tango",
+	"tapir", "teapot", "tempo", "tennis", "thorn", "thumb", "tiger", "timber", "toast", "token",
+	"tonic", "topaz", "torch", "tower", "toxic", "trail", "train", "trend", "tribe", "trout",
+	"truck", "tulip", "tundra", "turtle", "tweed", "twist", "ultra", "umbra", "uncle", "unity",
+	"upper", "urban", "usher", "valid", "valve", "vapor", "vault", "velvet", "venom", "verse",
+	"vessel", "video", "vigor", "vinyl", "viola", "viper", "visor", "vivid", "vocal", "voice",
+	"waffle", "wagon", "walnut", "walrus", "water", "waxen", "whale", "wheat", "whisk", "widget",
+	"willow", "wind", "wizard", "wolf", "woven", "wrist", "yacht", "yarn", "yearly", "yeast",
+	"yodel", "yogurt", "young", "zebra", "zesty", "zinc", "zippy", "zone",
+}